@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bradsec/gofindmyfonts/internal/app"
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+// runCommand runs a command-line subcommand. It reports false if name is not a subcommand.
+func runCommand(name string, args []string) (bool, error) {
+	switch name {
+	case "fallback":
+		return true, runFallback(args)
+	case "config":
+		return true, runConfig(args)
+	case "bench":
+		return true, runBench(args)
+	case "doctor":
+		return true, runDoctor(args)
+	default:
+		return false, nil
+	}
+}
+
+// runConfig prints the effective configuration after applying the config file,
+// environment variables and any flags given after "config show"
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprintf(os.Stderr, "Usage: %s config show [flags]\n", os.Args[0])
+		return fmt.Errorf("unknown config command")
+	}
+
+	config, err := app.LoadConfig(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if config == nil {
+		return err
+	}
+
+	switch {
+	case config.File == "":
+		fmt.Fprintln(os.Stderr, "# config file: none (no user config directory)")
+	case config.FileLoaded:
+		fmt.Fprintf(os.Stderr, "# config file: %s\n", config.File)
+	default:
+		fmt.Fprintf(os.Stderr, "# config file: %s (not found)\n", config.File)
+	}
+
+	data, marshalErr := json.Marshal(config)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	var out bytes.Buffer
+	json.Indent(&out, data, "", "  ")
+	out.WriteString("\n")
+	os.Stdout.Write(out.Bytes())
+
+	if err := errors.Join(err, config.Check()); err != nil {
+		return fmt.Errorf("invalid configuration:\n%v", err)
+	}
+	return nil
+}
+
+// doctorRecentErrors is how many ERROR records doctor reads from the log files
+const doctorRecentErrors = 20
+
+// runDoctor checks the configuration and environment, as the /diagnostics page
+// does, and reports the last errors in the log files. It fails if any check
+// does. Flags after "doctor" are config flags, except a leading -json.
+func runDoctor(args []string) error {
+	asJSON := false
+	if len(args) > 0 && (args[0] == "-json" || args[0] == "--json") {
+		asJSON = true
+		args = args[1:]
+	}
+
+	config, err := app.LoadConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if config == nil {
+		return err
+	}
+
+	recent, logErr := logging.ErrorsFromFiles(config.LogDir, doctorRecentErrors)
+	if logErr != nil && !errors.Is(logErr, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Could not read log files: %v\n", logErr)
+	}
+	report := app.RunDiagnostics(context.Background(), config, recent)
+	if err != nil {
+		report.ConfigErrors = append([]string{err.Error()}, report.ConfigErrors...)
+	}
+	problems := report.Problems()
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printDoctorReport(report)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problem(s) found", len(problems))
+	}
+	return nil
+}
+
+// printDoctorReport prints a diagnostics report as text
+func printDoctorReport(report *app.Diagnostics) {
+	switch {
+	case report.Config.File == "":
+		fmt.Println("Config file:  none (no user config directory)")
+	case report.Config.FileLoaded:
+		fmt.Printf("Config file:  %s\n", report.Config.File)
+	default:
+		fmt.Printf("Config file:  %s (not found)\n", report.Config.File)
+	}
+	fmt.Printf("Go:           %s %s/%s\n", report.GoVersion, report.OS, report.Arch)
+
+	fmt.Println("\nConversion tools:")
+	for _, tool := range report.Tools {
+		switch {
+		case tool.Error != "":
+			fmt.Printf("  FAIL  %-17s %s\n", tool.Name, tool.Error)
+		case tool.Version == "":
+			fmt.Printf("  ok    %-17s %s (version unknown)\n", tool.Name, tool.Path)
+		default:
+			fmt.Printf("  ok    %-17s %s (%s)\n", tool.Name, tool.Path, tool.Version)
+		}
+	}
+
+	fmt.Println("\nDirectories:")
+	for _, dir := range report.Dirs {
+		status, detail := "ok", dir.Path
+		if dir.Note != "" {
+			detail += " (" + dir.Note + ")"
+		}
+		if !dir.Writable {
+			status, detail = "FAIL", dir.Path+": "+dir.Error
+		}
+		fmt.Printf("  %-5s %-17s %s\n", status, dir.Name, detail)
+	}
+
+	fmt.Printf("\nConversion cache: %d files, %s in %s\n", report.Cache.Files, report.Cache.Size(), report.Cache.Path)
+	if report.Cache.Error != "" {
+		fmt.Printf("  %s\n", report.Cache.Error)
+	}
+
+	fmt.Printf("\nRecent errors in %s:\n", report.Config.LogDir)
+	if len(report.RecentErrors) == 0 {
+		fmt.Println("  none")
+	}
+	for _, entry := range report.RecentErrors {
+		fmt.Printf("  %s  %s", entry.Time.Format("2006-01-02 15:04:05"), entry.Message)
+		keys := make([]string, 0, len(entry.Attrs))
+		for key := range entry.Attrs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf(" %s=%s", key, strings.ReplaceAll(entry.Attrs[key], "\n", "; "))
+		}
+		fmt.Println()
+	}
+
+	if problems := report.Problems(); len(problems) > 0 {
+		fmt.Println("\nProblems:")
+		for _, problem := range problems {
+			fmt.Printf("  - %s\n", problem)
+		}
+	}
+}
+
+// runFallback prints @font-face overrides matching a local fallback font to a web font
+func runFallback(args []string) error {
+	flags := flag.NewFlagSet("fallback", flag.ContinueOnError)
+	fontPath := flags.String("font", "", "path to the web font (TTF, OTF or WOFF)")
+	fallbackPath := flags.String("fallback", "", "path to the local fallback font, e.g. Arial.ttf")
+	asJSON := flags.Bool("json", false, "print the result as JSON instead of CSS")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s fallback -font <web font> -fallback <local font> [-json]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *fontPath == "" || *fallbackPath == "" {
+		flags.Usage()
+		return fmt.Errorf("both -font and -fallback are required")
+	}
+
+	result, err := app.GenerateFallback(*fontPath, *fallbackPath)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	fmt.Print(result.CSS)
+	return nil
+}
+
+// runBench compares the serial directory walk with the concurrent scan walker
+func runBench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	dir := flags.String("dir", "", "walk this directory instead of a synthetic tree")
+	depth := flags.Int("depth", 4, "synthetic tree: directory levels below the root")
+	fanout := flags.Int("fanout", 6, "synthetic tree: subdirectories in each directory")
+	files := flags.Int("files", 8, "synthetic tree: font files in each directory")
+	latency := flags.Duration("latency", 0, "delay added to every directory read, e.g. 2ms to simulate a network share")
+	workers := flags.String("workers", "1,4,16,64", "comma separated worker counts for the concurrent walker")
+	runs := flags.Int("runs", 3, "runs of each walker; the fastest is reported")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s bench [flags]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	opts := app.WalkBenchOptions{Dir: *dir, Depth: *depth, Fanout: *fanout, Files: *files, Latency: *latency, Runs: *runs}
+	for _, value := range strings.Split(*workers, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid worker count %q", value)
+		}
+		opts.Workers = append(opts.Workers, n)
+	}
+
+	results, err := app.BenchWalk(opts)
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "WALKER\tWORKERS\tFILES\tTIME\tSPEEDUP")
+	for _, result := range results {
+		speedup := float64(results[0].Duration) / float64(result.Duration)
+		fmt.Fprintf(out, "%s\t%d\t%d\t%v\t%.1fx\n", result.Walker, result.Workers, result.Files, result.Duration.Round(time.Microsecond), speedup)
+	}
+	return out.Flush()
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -14,6 +17,9 @@ import (
 	"github.com/bradsec/gofindmyfonts/internal/logging"
 )
 
+// shutdownTimeout bounds how long active requests and conversions may take to finish on exit
+const shutdownTimeout = 30 * time.Second
+
 func showBanner() {
 	banner := `
 
@@ -36,25 +42,51 @@ func showBanner() {
 }
 
 func main() {
+	// Subcommands run without starting the server
+	if len(os.Args) > 1 {
+		handled, err := runCommand(os.Args[1], os.Args[2:])
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if handled {
+			return
+		}
+	}
+
+	// Anything else is configuration flags, e.g. -port 9000
+	config, err := app.LoadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		if config != nil {
+			err = errors.Join(err, config.Check())
+		}
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+
 	showBanner()
-	if err := run(); err != nil {
+	if err := run(config); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func run() error {
-	// Load and validate configuration
-	config := app.LoadConfig()
+func run(config *app.Config) error {
+	// Validate configuration and create working directories
 	if err := config.Validate(); err != nil {
 		return fmt.Errorf("invalid configuration: %v", err)
 	}
 
 	// Initialize logging
-	if err := logging.InitLogger(config.LogDir); err != nil {
+	if err := logging.Init(config.LogOptions()); err != nil {
 		return fmt.Errorf("failed to initialize logger: %v", err)
 	}
+	defer logging.Close()
 
-	// Create context for graceful shutdown
+	// Create context for background tasks
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -66,12 +98,37 @@ func run() error {
 	generator := app.NewPreviewGenerator(config)
 	defer generator.Close()
 
+	// Load favorites, tags and collections
+	library, err := app.NewLibraryStore(filepath.Join(config.DataDir, "library.json"))
+	if err != nil {
+		return fmt.Errorf("failed to load font library: %v", err)
+	}
+
 	// Create and start server
-	server := app.NewServer(generator)
+	server, err := app.NewServer(generator, library)
+	if err != nil {
+		return err
+	}
+	if config.Shared {
+		fmt.Printf("Shared mode: serving %s read-only at %s\n", config.Libraries, config.URL())
+		if config.UsersFile != "" {
+			fmt.Printf("Users must sign in with the accounts in %s\n\n", config.UsersFile)
+		} else if config.AccessToken == "" && config.RequiresToken() {
+			fmt.Printf("Share this address, replacing localhost with this machine's name or IP:\n\n  %s\n\n", server.LaunchURL())
+		} else {
+			fmt.Println()
+		}
+	} else if config.RequiresToken() {
+		fmt.Printf("LAN mode: the interface is reachable from other machines on port %s.\n", config.Port)
+		fmt.Printf("Open it with this address, replacing localhost with this machine's name or IP:\n\n  %s\n\n", server.LaunchURL())
+	} else {
+		fmt.Printf("Open %s\n\n", server.LaunchURL())
+	}
 
 	// Handle shutdown signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
 
 	// Start server in goroutine
 	errChan := make(chan error, 1)
@@ -80,25 +137,45 @@ func run() error {
 		errChan <- server.Start()
 	}()
 
-	// Open browser after delay
-	go func() {
-		time.Sleep(500 * time.Millisecond)
-		url := fmt.Sprintf("http://localhost:%s", config.Port)
-		if err := browser.OpenBrowser(url); err != nil {
-			logging.Error("Failed to open browser", "browser_open", "", err)
-		}
-	}()
+	// Open browser after delay; a shared server runs unattended
+	if !config.Shared {
+		go func() {
+			time.Sleep(500 * time.Millisecond)
+			if err := browser.OpenBrowser(server.LaunchURL()); err != nil {
+				logging.Error("Failed to open browser", "browser_open", "", err)
+			}
+		}()
+	}
 
 	// Wait for shutdown signal or error
 	select {
 	case err := <-errChan:
-		return fmt.Errorf("server error: %v", err)
+		if err != nil {
+			return fmt.Errorf("server error: %v", err)
+		}
+		return nil
 	case sig := <-sigChan:
 		logging.Info(fmt.Sprintf("Received signal %v, shutting down", sig), "shutdown", "")
-		cancel()
-		// Allow cleanup goroutines to finish (with timeout)
-		time.Sleep(2 * time.Second)
 	}
 
+	// A second signal skips the graceful shutdown
+	go func() {
+		sig := <-sigChan
+		logging.Error("Forced exit", "shutdown", "", fmt.Errorf("received second signal %v", sig))
+		logging.Close()
+		os.Exit(1)
+	}()
+
+	cancel()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown incomplete: %v", err)
+	}
+	if err := <-errChan; err != nil {
+		return fmt.Errorf("server error: %v", err)
+	}
+
+	logging.Info("Shutdown complete", "shutdown", "")
 	return nil
 }
@@ -0,0 +1,71 @@
+// internal/app/accesslog.go
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+// requestIDHeader carries the request ID from proxies and back to clients
+const requestIDHeader = "X-Request-ID"
+
+// newRequestID returns a random request ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// accessLog logs every request once it completes. Each request gets an ID,
+// taken from an incoming X-Request-ID header if it is a short token, that is
+// returned in the response and added to every record logged through the
+// request's context. Static files are logged at debug level and server errors
+// at error level.
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		id := r.Header.Get(requestIDHeader)
+		if !validJobID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		ctx := logging.With(r.Context(), "request_id", id)
+
+		cw := &countingWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r.WithContext(ctx))
+
+		status := cw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case strings.HasPrefix(r.URL.Path, "/static/") || r.URL.Path == "/favicon.ico":
+			level = slog.LevelDebug
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int64("bytes", cw.written),
+			slog.Float64("duration_ms", float64(time.Since(started).Microseconds())/1000),
+			slog.String("remote", r.RemoteAddr),
+		}
+		if query := r.URL.Query(); len(query) > 0 {
+			if query.Has("token") {
+				query.Set("token", "redacted")
+			}
+			attrs = append(attrs, slog.String("query", query.Encode()))
+		}
+		logging.FromContext(ctx).LogAttrs(ctx, level, "request", attrs...)
+	})
+}
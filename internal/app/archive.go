@@ -0,0 +1,371 @@
+// internal/app/archive.go
+package app
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradsec/gofindmyfonts/internal/sfnt"
+)
+
+// archiveSeparator joins the path of a zip archive and the name of a member,
+// as in Fonts.zip!/Regular/Font.ttf
+const archiveSeparator = "!/"
+
+// maxOpenArchives bounds how many archive indexes a fontFiles keeps open
+const maxOpenArchives = 32
+
+// fontFile is an open font: a file on disk, or an archive member streamed
+// out of its archive
+type fontFile interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// memberInfo describes an archive member. Its modification time is the
+// archive's, so caches keyed on it are invalidated when the archive changes.
+type memberInfo struct {
+	os.FileInfo
+	modTime time.Time
+}
+
+func (i memberInfo) ModTime() time.Time { return i.modTime }
+
+// splitArchivePath splits "Fonts.zip!/Regular/Font.ttf" into the archive path
+// and the slash-separated member name. It reports false for a plain path.
+func splitArchivePath(fontPath string) (archive, member string, ok bool) {
+	lower := strings.ToLower(fontPath)
+	for _, sep := range []string{".zip" + archiveSeparator, ".zip!" + string(filepath.Separator)} {
+		if i := strings.Index(lower, sep); i >= 0 {
+			return fontPath[:i+len(".zip")], filepath.ToSlash(fontPath[i+len(sep):]), true
+		}
+	}
+	return "", "", false
+}
+
+// memberName returns the slash-separated name of a zip member, or "" for a
+// directory or a name that would escape the archive
+func memberName(f *zip.File) string {
+	name := strings.ReplaceAll(f.Name, `\`, "/")
+	if f.FileInfo().IsDir() || strings.HasPrefix(name, "/") || path.Clean(name) != name ||
+		name == ".." || strings.HasPrefix(name, "../") {
+		return ""
+	}
+	return name
+}
+
+// fontFiles opens font files and the members of zip archives. The index of
+// each archive is read once and kept open for later calls, until the archive
+// changes on disk or more than maxOpenArchives archives are in use. Members
+// larger than maxSize are refused before any of them is read.
+type fontFiles struct {
+	maxSize int64
+
+	mu       sync.Mutex
+	archives map[string]*archiveIndex
+	order    []string // Archive paths, least recently used first
+}
+
+// archiveIndex is an open zip archive and its members by name
+type archiveIndex struct {
+	file    *os.File
+	info    os.FileInfo
+	reader  *zip.Reader
+	members map[string]*zip.File
+	refs    int // Open member readers, plus one while cached
+}
+
+func newFontFiles(maxSize int64) *fontFiles {
+	return &fontFiles{maxSize: maxSize, archives: make(map[string]*archiveIndex)}
+}
+
+// archive returns the index of an archive, reading it if it is not cached or
+// has changed since. The caller must release it.
+func (f *fontFiles) archive(archive string) (*archiveIndex, error) {
+	info, err := os.Stat(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if index, ok := f.archives[archive]; ok {
+		if index.info.Size() == info.Size() && index.info.ModTime().Equal(info.ModTime()) {
+			f.touch(archive)
+			index.refs++
+			return index, nil
+		}
+		f.evict(archive)
+	}
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	index := &archiveIndex{file: file, info: info, reader: reader, members: make(map[string]*zip.File), refs: 2}
+	for _, member := range reader.File {
+		if name := memberName(member); name != "" {
+			index.members[name] = member
+		}
+	}
+	f.archives[archive] = index
+	f.order = append(f.order, archive)
+	for len(f.order) > maxOpenArchives {
+		f.evict(f.order[0])
+	}
+	return index, nil
+}
+
+// touch marks archive as the most recently used. f.mu must be held.
+func (f *fontFiles) touch(archive string) {
+	for i, name := range f.order {
+		if name == archive {
+			f.order = append(append(f.order[:i:i], f.order[i+1:]...), archive)
+			return
+		}
+	}
+}
+
+// evict drops archive from the cache; it is closed once its last reader is.
+// f.mu must be held.
+func (f *fontFiles) evict(archive string) {
+	index, ok := f.archives[archive]
+	if !ok {
+		return
+	}
+	delete(f.archives, archive)
+	for i, name := range f.order {
+		if name == archive {
+			f.order = append(f.order[:i:i], f.order[i+1:]...)
+			break
+		}
+	}
+	f.releaseLocked(index)
+}
+
+// release gives back an index returned by archive
+func (f *fontFiles) release(index *archiveIndex) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.releaseLocked(index)
+}
+
+func (f *fontFiles) releaseLocked(index *archiveIndex) {
+	index.refs--
+	if index.refs == 0 {
+		index.file.Close()
+	}
+}
+
+// close drops every cached archive. Open member readers keep working until
+// they are closed.
+func (f *fontFiles) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.order) > 0 {
+		f.evict(f.order[0])
+	}
+}
+
+// member returns a member of an open archive
+func (index *archiveIndex) member(member string) (*zip.File, error) {
+	if f, ok := index.members[member]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("%s: %w", member, os.ErrNotExist)
+}
+
+// stat describes a member. Its modification time is the archive's, so caches
+// keyed on it are invalidated when the archive changes.
+func (index *archiveIndex) stat(f *zip.File) os.FileInfo {
+	return memberInfo{FileInfo: f.FileInfo(), modTime: index.info.ModTime()}
+}
+
+// listArchive returns the paths of the font files inside a zip archive.
+// Hidden members and macOS resource forks are skipped unless includeHidden is
+// set; archives inside the archive are not opened.
+func (f *fontFiles) listArchive(archive string, includeHidden bool) ([]string, error) {
+	index, err := f.archive(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.release(index)
+
+	var fonts []string
+	for _, member := range index.reader.File {
+		name := memberName(member)
+		if name == "" || !allowedExts[strings.ToLower(path.Ext(name))] {
+			continue
+		}
+		if !includeHidden && (strings.HasPrefix(name, "__MACOSX/") ||
+			strings.HasPrefix(name, ".") || strings.Contains(name, "/.")) {
+			continue
+		}
+		fonts = append(fonts, archive+archiveSeparator+name)
+	}
+	return fonts, nil
+}
+
+// memberReader streams an archive member. The member is decompressed as it is
+// read and never holds more than one read's worth in memory. Seeking forward
+// skips ahead; seeking backward starts decompressing again from the start.
+type memberReader struct {
+	files  *fontFiles
+	index  *archiveIndex
+	member *zip.File
+	size   int64
+
+	rc     io.ReadCloser // Decompressor, opened on first read
+	r      io.Reader     // rc limited to size
+	pos    int64         // Offset of the next byte r returns
+	offset int64         // Offset the caller seeked to
+}
+
+func (m *memberReader) Read(p []byte) (int, error) {
+	if m.offset >= m.size {
+		return 0, io.EOF
+	}
+	if m.rc == nil || m.offset < m.pos {
+		if err := m.reopen(); err != nil {
+			return 0, err
+		}
+	}
+	if m.offset > m.pos {
+		skipped, err := io.CopyN(io.Discard, m.r, m.offset-m.pos)
+		m.pos += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := m.r.Read(p)
+	m.pos += int64(n)
+	m.offset = m.pos
+	if err == io.EOF && m.pos < m.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (m *memberReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += m.offset
+	case io.SeekEnd:
+		offset += m.size
+	default:
+		return 0, fmt.Errorf("seek: invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("seek: negative position %d", offset)
+	}
+	m.offset = offset
+	return offset, nil
+}
+
+// reopen starts decompressing the member again from its first byte
+func (m *memberReader) reopen() error {
+	if m.rc != nil {
+		m.rc.Close()
+		m.rc = nil
+	}
+	rc, err := m.member.Open()
+	if err != nil {
+		return err
+	}
+	// The header's size has been checked against the limit; never read past it
+	m.rc, m.r, m.pos = rc, io.LimitReader(rc, m.size), 0
+	return nil
+}
+
+func (m *memberReader) Close() error {
+	if m.index == nil {
+		return nil
+	}
+	if m.rc != nil {
+		m.rc.Close()
+	}
+	m.files.release(m.index)
+	m.index = nil
+	return nil
+}
+
+// open opens a font file or archive member for reading
+func (f *fontFiles) open(fontPath string) (fontFile, os.FileInfo, error) {
+	archive, member, ok := splitArchivePath(fontPath)
+	if !ok {
+		file, err := os.Open(fontPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return file, info, nil
+	}
+
+	index, err := f.archive(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, err := index.member(member)
+	if err == nil && entry.UncompressedSize64 > uint64(f.maxSize) {
+		err = fmt.Errorf("%s: member size %d exceeds limit of %d bytes", member, entry.UncompressedSize64, f.maxSize)
+	}
+	if err != nil {
+		f.release(index)
+		return nil, nil, err
+	}
+	reader := &memberReader{files: f, index: index, member: entry, size: int64(entry.UncompressedSize64)}
+	return reader, index.stat(entry), nil
+}
+
+// stat returns the file info of a font file or archive member
+func (f *fontFiles) stat(fontPath string) (os.FileInfo, error) {
+	archive, member, ok := splitArchivePath(fontPath)
+	if !ok {
+		return os.Stat(fontPath)
+	}
+
+	index, err := f.archive(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.release(index)
+	entry, err := index.member(member)
+	if err != nil {
+		return nil, err
+	}
+	return index.stat(entry), nil
+}
+
+// parse parses a font file or archive member
+func (f *fontFiles) parse(fontPath string) (*sfnt.Font, error) {
+	if _, _, ok := splitArchivePath(fontPath); !ok {
+		return sfnt.ParseFile(fontPath)
+	}
+	file, _, err := f.open(fontPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return sfnt.Parse(data)
+}
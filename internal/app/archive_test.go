@@ -0,0 +1,163 @@
+// internal/app/archive_test.go
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeArchive writes a zip archive of deflated members
+func writeArchive(t *testing.T, path string, members map[string][]byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range members {
+		entry, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// randomBytes returns n bytes that do not compress
+func randomBytes(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func TestArchiveMemberOverLimitRefused(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "Fonts.zip")
+	writeArchive(t, archive, map[string][]byte{"Big.ttf": randomBytes(4096), "Small.ttf": randomBytes(64)})
+
+	files := newFontFiles(1024)
+	defer files.close()
+	if _, _, err := files.open(archive + archiveSeparator + "Big.ttf"); err == nil || !strings.Contains(err.Error(), "exceeds limit") {
+		t.Errorf("opening a member over the limit returned %v", err)
+	}
+	if _, err := files.parse(archive + archiveSeparator + "Big.ttf"); err == nil || !strings.Contains(err.Error(), "exceeds limit") {
+		t.Errorf("parsing a member over the limit returned %v", err)
+	}
+	file, _, err := files.open(archive + archiveSeparator + "Small.ttf")
+	if err != nil {
+		t.Fatalf("member under the limit: %v", err)
+	}
+	file.Close()
+}
+
+func TestArchiveMemberSeeks(t *testing.T) {
+	data := randomBytes(300 << 10)
+	archive := filepath.Join(t.TempDir(), "Fonts.zip")
+	writeArchive(t, archive, map[string][]byte{"Font.ttf": data})
+	files := newFontFiles(DefaultMaxFileSize)
+	defer files.close()
+
+	file, info, err := files.open(archive + archiveSeparator + "Font.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if info.Size() != int64(len(data)) {
+		t.Fatalf("size %d, want %d", info.Size(), len(data))
+	}
+
+	read := func(offset int64, whence int, n int) []byte {
+		t.Helper()
+		if _, err := file.Seek(offset, whence); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, n)
+		if _, err := io.ReadFull(file, got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	for _, tc := range []struct {
+		name   string
+		offset int64
+		whence int
+		start  int
+	}{
+		{"forward", 200 << 10, io.SeekStart, 200 << 10},
+		{"backward", 10, io.SeekStart, 10},
+		{"current", 1000, io.SeekCurrent, 10 + 64 + 1000},
+		{"end", -64, io.SeekEnd, len(data) - 64},
+	} {
+		if got := read(tc.offset, tc.whence, 64); !bytes.Equal(got, data[tc.start:tc.start+64]) {
+			t.Errorf("%s: read the wrong bytes", tc.name)
+		}
+	}
+	if n, err := file.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("read at the end returned %d, %v", n, err)
+	}
+
+	// Range requests are served from the stream
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/download", nil)
+	req.Header.Set("Range", "bytes=100000-100099")
+	w := httptest.NewRecorder()
+	http.ServeContent(w, req, "Font.ttf", info.ModTime(), file)
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), data[100000:100100]) {
+		t.Errorf("range request returned %d with %d bytes", w.Code, w.Body.Len())
+	}
+}
+
+func TestArchiveIndexReused(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "Fonts.zip")
+	writeArchive(t, archive, map[string][]byte{"Sans.ttf": []byte("first")})
+	files := newFontFiles(DefaultMaxFileSize)
+	defer files.close()
+
+	fonts, err := files.listArchive(archive, false)
+	if err != nil || len(fonts) != 1 {
+		t.Fatalf("listed %v, %v", fonts, err)
+	}
+	index := files.archives[archive]
+	if _, err := files.stat(fonts[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := files.parse(fonts[0]); err == nil {
+		t.Fatal("parsed a member that is not a font")
+	}
+	if files.archives[archive] != index || len(files.archives) != 1 {
+		t.Error("the archive index was read again")
+	}
+
+	// A changed archive is read again
+	writeArchive(t, archive, map[string][]byte{"Sans.ttf": []byte("second version")})
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(archive, later, later); err != nil {
+		t.Fatal(err)
+	}
+	file, _, err := files.open(fonts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if got, _ := io.ReadAll(file); string(got) != "second version" {
+		t.Errorf("read %q from the changed archive", got)
+	}
+	if files.archives[archive] == index {
+		t.Error("the stale archive index was used")
+	}
+}
@@ -47,7 +47,9 @@ func (cm *CleanupManager) CleanOldFiles() error {
 			if err := os.Remove(path); err != nil {
 				logging.Error("Failed to remove old file", "cleanup", path, err)
 			} else {
-				logging.Info("Removed old file", "cleanup", path)
+				logging.Debug("Removed old file", "cleanup", path)
+				cleanupRemovedFiles.Inc()
+				cleanupRemovedBytes.Add(float64(info.Size()))
 			}
 		}
 	}
@@ -56,7 +58,7 @@ func (cm *CleanupManager) CleanOldFiles() error {
 }
 
 func (cm *CleanupManager) ScheduleCleanup(ctx context.Context) {
-	ticker := time.NewTicker(6 * time.Hour)
+	ticker := time.NewTicker(cm.config.CleanupInterval)
 	go func() {
 		for {
 			select {
@@ -65,7 +67,7 @@ func (cm *CleanupManager) ScheduleCleanup(ctx context.Context) {
 				return
 			case <-ticker.C:
 				if err := cm.CleanOldFiles(); err != nil {
-					logging.Error("Scheduled cleanup failed", "cleanup", "", err)
+					logging.ErrorContext(ctx, "Scheduled cleanup failed", "cleanup", "", err)
 				}
 			}
 		}
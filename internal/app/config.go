@@ -1,87 +1,639 @@
 package app
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
 )
 
 const (
-	DefaultPort             = "8080"
-	DefaultMaxConcurrent    = 4
-	DefaultPreviewCacheTime = 24 * time.Hour
-	DefaultFontSize         = 48.0
-	DefaultMaxFileSize      = 50 * 1024 * 1024 // 50MB
+	DefaultPort              = "8080"
+	DefaultBindAddress       = "127.0.0.1"
+	DefaultMaxConcurrent     = 4
+	DefaultPreviewCacheTime  = 24 * time.Hour
+	DefaultFontSize          = 48.0
+	DefaultMaxFileSize       = 50 * 1024 * 1024 // 50MB
+	DefaultConversionTimeout = 2 * time.Minute
+	DefaultQuarantineAfter   = 3
+	DefaultCleanupInterval   = 6 * time.Hour
+	DefaultLogLevel          = "info"
+	DefaultConsoleLogLevel   = "warn"
+	DefaultLogMaxSize        = 10 * 1024 * 1024 // 10MB
+	DefaultLogMaxFiles       = 14
+	DefaultScanWorkers       = 16 // Directory reads mostly wait on the disk or network, so this can exceed the CPUs
 )
 
+// ConfigFileEnv overrides the location of the config file
+const ConfigFileEnv = "GOFINDMYFONTS_CONFIG"
+
 type Config struct {
-	Port             string
-	StaticDir        string
-	LogDir           string
-	MaxConcurrent    int
-	PreviewCacheTime time.Duration
-	FontSize         float64
-	MaxFileSize      int64
+	Port              string
+	BindAddress       string // Interface to listen on; empty for all interfaces
+	LAN               bool   // Allow access from other machines, protected by an access token
+	StaticDir         string
+	LogDir            string
+	LogLevel          string // Least severe level written to the log file
+	ConsoleLogLevel   string // Least severe level printed to the console
+	LogFormat         string // "json" or "text" for the log file
+	ConsoleLogFormat  string // "json" or "text" for the console
+	LogMaxSize        int64  // Bytes per log file before starting another; 0 rotates only daily
+	LogMaxFiles       int    // Log files kept; 0 keeps all
+	DataDir           string
+	MaxConcurrent     int
+	PreviewCacheTime  time.Duration
+	FontSize          float64
+	MaxFileSize       int64
+	ConversionTimeout time.Duration
+	QuarantineAfter   int
+	ConvertOnScan     bool // Convert missing formats during scans instead of on first download
+	CleanupInterval   time.Duration
+
+	// Defaults for the scan policy; requests can override them
+	ScanFollowSymlinks bool
+	ScanHidden         bool
+	ScanArchives       bool
+	ScanExclude        []string
+	ScanMaxDepth       int
+	ScanMaxFiles       int
+	ScanWorkers        int // Directories read at the same time
+
+	// Shared mode serves only the configured libraries, read-only, to a team
+	Shared      bool
+	Libraries   LibraryDirs
+	UsersFile   string // "name:password" lines; enables basic auth
+	AccessToken string // Fixed access token instead of a per-launch one
+
+	// File is the config file that was looked for, and FileLoaded whether it existed
+	File       string
+	FileLoaded bool
 }
 
-func LoadConfig() *Config {
-	config := &Config{
-		Port:             getEnvOrDefault("PORT", DefaultPort),
-		StaticDir:        filepath.Join(".", "static"),
-		LogDir:           filepath.Join(".", "logs"),
-		MaxConcurrent:    getEnvIntOrDefault("MAX_CONCURRENT", DefaultMaxConcurrent),
-		PreviewCacheTime: DefaultPreviewCacheTime,
-		FontSize:         DefaultFontSize,
-		MaxFileSize:      DefaultMaxFileSize,
+// setting describes one Config field and the names it is read from in each layer
+type setting struct {
+	key   string      // Key in the config file
+	env   string      // Environment variable
+	flag  string      // Command line flag
+	usage string      // Description for -help
+	value interface{} // Pointer to the Config field
+}
+
+// settings lists every configurable field, in the order they are shown
+func (c *Config) settings() []setting {
+	return []setting{
+		{"port", "PORT", "port", "port the web interface listens on", &c.Port},
+		{"bindAddress", "BIND_ADDRESS", "bind", "address to listen on; non-loopback addresses require -lan", &c.BindAddress},
+		{"lan", "GOFINDMYFONTS_LAN", "lan", "listen on all interfaces so other machines can connect, with an access token", &c.LAN},
+		{"staticDir", "STATIC_DIR", "static-dir", "directory for converted fonts", &c.StaticDir},
+		{"logDir", "LOG_DIR", "log-dir", "directory for log files", &c.LogDir},
+		{"logLevel", "LOG_LEVEL", "log-level", "least severe level written to the log file: debug, info, warn or error", &c.LogLevel},
+		{"consoleLogLevel", "CONSOLE_LOG_LEVEL", "console-log-level", "least severe level printed to the console: debug, info, warn or error", &c.ConsoleLogLevel},
+		{"logFormat", "LOG_FORMAT", "log-format", "log file format: json or text", &c.LogFormat},
+		{"consoleLogFormat", "CONSOLE_LOG_FORMAT", "console-log-format", "console log format: json or text", &c.ConsoleLogFormat},
+		{"logMaxSize", "LOG_MAX_SIZE", "log-max-size", "bytes written to a log file before starting the next; 0 rotates only daily", &c.LogMaxSize},
+		{"logMaxFiles", "LOG_MAX_FILES", "log-max-files", "log files to keep; 0 keeps all", &c.LogMaxFiles},
+		{"dataDir", "DATA_DIR", "data-dir", "directory for the library and quarantine", &c.DataDir},
+		{"maxConcurrent", "MAX_CONCURRENT", "max-concurrent", "maximum number of concurrent conversions", &c.MaxConcurrent},
+		{"previewCacheTime", "PREVIEW_CACHE_TIME", "preview-cache-time", "how long converted fonts are kept and cached by browsers", &c.PreviewCacheTime},
+		{"fontSize", "FONT_SIZE", "font-size", "default preview font size in pixels", &c.FontSize},
+		{"maxFileSize", "MAX_FILE_SIZE", "max-file-size", "largest font file in bytes that will be converted", &c.MaxFileSize},
+		{"conversionTimeout", "CONVERSION_TIMEOUT", "conversion-timeout", "time limit for a single conversion", &c.ConversionTimeout},
+		{"quarantineAfter", "QUARANTINE_AFTER", "quarantine-after", "failed conversions before a font is no longer converted", &c.QuarantineAfter},
+		{"convertOnScan", "CONVERT_ON_SCAN", "convert-on-scan", "convert missing formats during scans instead of on first download", &c.ConvertOnScan},
+		{"cleanupInterval", "CLEANUP_INTERVAL", "cleanup-interval", "how often expired converted fonts are removed", &c.CleanupInterval},
+		{"followSymlinks", "SCAN_FOLLOW_SYMLINKS", "follow-symlinks", "follow symlinked directories when scanning", &c.ScanFollowSymlinks},
+		{"scanHidden", "SCAN_HIDDEN", "scan-hidden", "scan hidden files and directories", &c.ScanHidden},
+		{"scanArchives", "SCAN_ARCHIVES", "scan-archives", "index the font files inside .zip archives without extracting them", &c.ScanArchives},
+		{"exclude", "SCAN_EXCLUDE", "exclude", "gitignore-style pattern to skip when scanning; repeat the flag, or separate patterns with commas", &c.ScanExclude},
+		{"maxDepth", "SCAN_MAX_DEPTH", "max-depth", "directory levels to scan, 1 for only the chosen directory; 0 for no limit", &c.ScanMaxDepth},
+		{"maxFiles", "SCAN_MAX_FILES", "max-files", "font files to collect before a scan stops; 0 for no limit", &c.ScanMaxFiles},
+		{"scanWorkers", "SCAN_WORKERS", "scan-workers", "directories and font files a scan reads at the same time", &c.ScanWorkers},
+		{"shared", "GOFINDMYFONTS_SHARED", "shared", "serve only the configured libraries, read-only, without opening a browser", &c.Shared},
+		{"libraries", "GOFINDMYFONTS_LIBRARIES", "library", "font library as Name=path; repeat the flag, or separate entries with " + string(os.PathListSeparator), &c.Libraries},
+		{"usersFile", "USERS_FILE", "users-file", "file of name:password lines required via basic auth", &c.UsersFile},
+		{"accessToken", "ACCESS_TOKEN", "access-token", "fixed access token to require instead of a per-launch one", &c.AccessToken},
 	}
+}
+
+// LibraryDir is a font directory offered by name in shared mode
+type LibraryDir struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
 
-	if maxSize := os.Getenv("MAX_FILE_SIZE"); maxSize != "" {
-		if size, err := strconv.ParseInt(maxSize, 10, 64); err == nil {
-			config.MaxFileSize = size
+// LibraryDirs is the list of configured libraries
+type LibraryDirs []LibraryDir
+
+// parseLibraryDirs reads Name=path entries separated by the OS path list
+// separator. An entry without a name is named after its directory.
+func parseLibraryDirs(raw string) LibraryDirs {
+	var dirs LibraryDirs
+	for _, entry := range filepath.SplitList(raw) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, path, ok := strings.Cut(entry, "=")
+		if !ok {
+			name, path = filepath.Base(entry), entry
 		}
+		dirs = append(dirs, LibraryDir{Name: strings.TrimSpace(name), Path: strings.TrimSpace(path)})
 	}
+	return dirs
+}
 
-	return config
+func (l LibraryDirs) String() string {
+	entries := make([]string, len(l))
+	for i, dir := range l {
+		entries[i] = dir.Name + "=" + dir.Path
+	}
+	return strings.Join(entries, string(os.PathListSeparator))
 }
 
-func (c *Config) Validate() error {
+// Find returns the path of the library called name
+func (l LibraryDirs) Find(name string) (string, bool) {
+	for _, dir := range l {
+		if dir.Name == name {
+			return dir.Path, true
+		}
+	}
+	return "", false
+}
+
+// DefaultConfig returns the built-in configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Port:              DefaultPort,
+		BindAddress:       DefaultBindAddress,
+		StaticDir:         filepath.Join(".", "static"),
+		LogDir:            filepath.Join(".", "logs"),
+		LogLevel:          DefaultLogLevel,
+		ConsoleLogLevel:   DefaultConsoleLogLevel,
+		LogFormat:         logging.FormatJSON,
+		ConsoleLogFormat:  logging.FormatText,
+		LogMaxSize:        DefaultLogMaxSize,
+		LogMaxFiles:       DefaultLogMaxFiles,
+		DataDir:           filepath.Join(".", "data"),
+		MaxConcurrent:     DefaultMaxConcurrent,
+		PreviewCacheTime:  DefaultPreviewCacheTime,
+		FontSize:          DefaultFontSize,
+		MaxFileSize:       DefaultMaxFileSize,
+		ConversionTimeout: DefaultConversionTimeout,
+		QuarantineAfter:   DefaultQuarantineAfter,
+		CleanupInterval:   DefaultCleanupInterval,
+		ScanExclude:       []string{"node_modules"},
+		ScanWorkers:       DefaultScanWorkers,
+	}
+}
+
+// DefaultConfigFile returns the config file location: $GOFINDMYFONTS_CONFIG, or
+// config.json in the gofindmyfonts directory of the user config directory
+// ($XDG_CONFIG_HOME or ~/.config on Linux).
+func DefaultConfigFile() string {
+	if path := os.Getenv(ConfigFileEnv); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gofindmyfonts", "config.json")
+}
+
+// LoadConfig builds the configuration in layers: built-in defaults, then the
+// config file, then environment variables, then command line flags in args.
+// A missing config file is not an error. All invalid values are reported
+// together.
+func LoadConfig(args []string) (*Config, error) {
+	config := DefaultConfig()
+	settings := config.settings()
+
+	// Flags are parsed first so -config can choose the file, but applied last
+	type flagValue struct {
+		setting setting
+		raw     string
+	}
+	var flagValues []flagValue
+	flags := flag.NewFlagSet("gofindmyfonts", flag.ContinueOnError)
+	configFile := flags.String("config", DefaultConfigFile(), "path to the JSON config file")
+	for _, s := range settings {
+		s := s
+		set := func(raw string) error {
+			// Repeated list flags add entries rather than replace them
+			if separator, isList := listSeparator(s.value); isList {
+				for i := range flagValues {
+					if flagValues[i].setting.key == s.key {
+						flagValues[i].raw += separator + raw
+						return nil
+					}
+				}
+			}
+			flagValues = append(flagValues, flagValue{s, raw})
+			return nil
+		}
+		if _, isBool := s.value.(*bool); isBool {
+			flags.BoolFunc(s.flag, s.usage, set)
+		} else {
+			flags.Func(s.flag, fmt.Sprintf("%s (default %s)", s.usage, formatValue(s.value)), set)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	var errs []error
+
+	config.File = *configFile
+	if config.File != "" {
+		loaded, err := config.loadFile(config.File)
+		config.FileLoaded = loaded
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, s := range settings {
+		if raw := os.Getenv(s.env); raw != "" {
+			if err := setValue(s.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("environment variable %s: %w", s.env, err))
+			}
+		}
+	}
+
+	for _, fv := range flagValues {
+		if err := setValue(fv.setting.value, fv.raw); err != nil {
+			errs = append(errs, fmt.Errorf("flag -%s: %w", fv.setting.flag, err))
+		}
+	}
+
+	// LAN mode opens the loopback default up to all interfaces
+	if config.LAN && isLoopbackHost(config.BindAddress) {
+		config.BindAddress = ""
+	}
+
+	return config, errors.Join(errs...)
+}
+
+// loadFile applies the settings in a JSON config file. It reports whether the file exists.
+func (c *Config) loadFile(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return true, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	byKey := make(map[string]setting)
+	for _, s := range c.settings() {
+		byKey[s.key] = s
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		raw := values[key]
+		s, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %q", path, key))
+			continue
+		}
+		if err := setJSONValue(s.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: %s: %w", path, key, err))
+		}
+	}
+	return true, errors.Join(errs...)
+}
+
+// listSeparator returns the separator between entries of a list setting
+func listSeparator(value interface{}) (string, bool) {
+	switch value.(type) {
+	case *LibraryDirs:
+		return string(os.PathListSeparator), true
+	case *[]string:
+		return ",", true
+	}
+	return "", false
+}
+
+// setValue parses raw into the Config field value points to
+func setValue(value interface{}, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch v := value.(type) {
+	case *string:
+		*v = raw
+	case *int:
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		*v = i
+	case *int64:
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		*v = i
+	case *float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		*v = f
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		*v = b
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, e.g. 90s or 2h", raw)
+		}
+		*v = d
+	case *LibraryDirs:
+		*v = parseLibraryDirs(raw)
+	case *[]string:
+		*v = splitList(raw)
+	default:
+		return fmt.Errorf("unsupported setting type %T", value)
+	}
+	return nil
+}
+
+// setJSONValue decodes a config file value into the Config field value points to.
+// Durations are written as strings such as "2m".
+func setJSONValue(value interface{}, raw json.RawMessage) error {
+	if d, ok := value.(*time.Duration); ok {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Errorf("expected a duration string such as \"2m\"")
+		}
+		return setValue(d, s)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if err := decoder.Decode(value); err != nil {
+		return fmt.Errorf("invalid value %s", raw)
+	}
+	return nil
+}
+
+// formatValue renders a Config field default for help text
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case *string:
+		return strconv.Quote(*v)
+	case *time.Duration:
+		return v.String()
+	case *int:
+		return strconv.Itoa(*v)
+	case *int64:
+		return strconv.FormatInt(*v, 10)
+	case *float64:
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	case *bool:
+		return strconv.FormatBool(*v)
+	case *LibraryDirs:
+		return v.String()
+	case *[]string:
+		return strings.Join(*v, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// MarshalJSON writes the settings in config file form, so `config show` output
+// can be used as a config file. The access token is redacted.
+func (c *Config) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, s := range c.settings() {
+		var value interface{} = s.value
+		if d, ok := s.value.(*time.Duration); ok {
+			value = d.String()
+		}
+		if s.key == "accessToken" && c.AccessToken != "" {
+			value = "(redacted)"
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "%q:%s", s.key, data)
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
+
+// Addr returns the host:port the server listens on
+func (c *Config) Addr() string {
+	return net.JoinHostPort(c.BindAddress, c.Port)
+}
+
+// isLoopbackHost reports whether host only accepts connections from this machine
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// RequiresToken reports whether clients must present the per-launch access
+// token, which is the case whenever the server is reachable from other machines
+func (c *Config) RequiresToken() bool {
+	return !isLoopbackHost(c.BindAddress)
+}
+
+// URL returns the address of the web interface for the browser. Wildcard
+// bind addresses are shown as localhost.
+func (c *Config) URL() string {
+	host := c.BindAddress
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, c.Port)
+}
+
+// LogOptions returns the logging settings. The levels and formats must have passed Check.
+func (c *Config) LogOptions() logging.Options {
+	fileLevel, _ := logging.ParseLevel(c.LogLevel)
+	consoleLevel, _ := logging.ParseLevel(c.ConsoleLogLevel)
+	fileFormat, _ := logging.ParseFormat(c.LogFormat)
+	consoleFormat, _ := logging.ParseFormat(c.ConsoleLogFormat)
+	return logging.Options{
+		Dir:           c.LogDir,
+		FileLevel:     fileLevel,
+		ConsoleLevel:  consoleLevel,
+		FileFormat:    fileFormat,
+		ConsoleFormat: consoleFormat,
+		MaxSize:       c.LogMaxSize,
+		MaxFiles:      c.LogMaxFiles,
+	}
+}
+
+// Check reports every invalid setting without touching the file system
+func (c *Config) Check() error {
+	var errs []error
+
 	if c.Port == "" {
-		return fmt.Errorf("port cannot be empty")
+		errs = append(errs, fmt.Errorf("port cannot be empty"))
+	} else if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be a number between 1 and 65535, got %q", c.Port))
+	}
+
+	if c.BindAddress != "" && c.BindAddress != "localhost" && net.ParseIP(c.BindAddress) == nil {
+		errs = append(errs, fmt.Errorf("bindAddress must be an IP address or localhost, got %q", c.BindAddress))
+	} else if !c.LAN && !isLoopbackHost(c.BindAddress) {
+		errs = append(errs, fmt.Errorf("bindAddress %q is reachable from other machines; enable lan to allow this", c.BindAddress))
+	}
+
+	// Settings are checked in the order they are listed, so errors are too
+	for _, dir := range []struct{ name, value string }{
+		{"staticDir", c.StaticDir}, {"logDir", c.LogDir}, {"dataDir", c.DataDir},
+	} {
+		if dir.value == "" {
+			errs = append(errs, fmt.Errorf("%s cannot be empty", dir.name))
+		}
+	}
+
+	for _, level := range []struct{ name, value string }{
+		{"logLevel", c.LogLevel}, {"consoleLogLevel", c.ConsoleLogLevel},
+	} {
+		if _, err := logging.ParseLevel(level.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", level.name, err))
+		}
+	}
+
+	for _, format := range []struct{ name, value string }{
+		{"logFormat", c.LogFormat}, {"consoleLogFormat", c.ConsoleLogFormat},
+	} {
+		if _, err := logging.ParseFormat(format.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", format.name, err))
+		}
+	}
+
+	if c.LogMaxSize < 0 {
+		errs = append(errs, fmt.Errorf("logMaxSize cannot be negative"))
+	}
+
+	if c.LogMaxFiles < 0 {
+		errs = append(errs, fmt.Errorf("logMaxFiles cannot be negative"))
 	}
 
 	if c.MaxConcurrent < 1 {
-		return fmt.Errorf("maxConcurrent must be at least 1")
+		errs = append(errs, fmt.Errorf("maxConcurrent must be at least 1"))
+	}
+
+	if c.PreviewCacheTime <= 0 {
+		errs = append(errs, fmt.Errorf("previewCacheTime must be positive"))
+	}
+
+	if c.MaxFileSize <= 0 {
+		errs = append(errs, fmt.Errorf("maxFileSize must be positive"))
+	}
+
+	if c.ConversionTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("conversionTimeout must be positive"))
+	}
+
+	if c.QuarantineAfter < 1 {
+		errs = append(errs, fmt.Errorf("quarantineAfter must be at least 1"))
 	}
 
 	if c.FontSize <= 0 {
-		return fmt.Errorf("fontSize must be positive")
+		errs = append(errs, fmt.Errorf("fontSize must be positive"))
 	}
 
-	// Ensure directories exist
-	dirs := []string{c.StaticDir, c.LogDir}
-	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory %s: %v", dir, err)
+	if c.CleanupInterval <= 0 {
+		errs = append(errs, fmt.Errorf("cleanupInterval must be positive"))
+	}
+
+	if c.Shared && len(c.Libraries) == 0 {
+		errs = append(errs, fmt.Errorf("shared mode needs at least one library"))
+	}
+	names := make(map[string]bool)
+	for _, dir := range c.Libraries {
+		switch {
+		case dir.Name == "" || dir.Path == "":
+			errs = append(errs, fmt.Errorf("library %q needs both a name and a path", dir.Name+"="+dir.Path))
+		case names[dir.Name]:
+			errs = append(errs, fmt.Errorf("library name %q is used more than once", dir.Name))
 		}
+		names[dir.Name] = true
 	}
 
-	return nil
-}
+	if c.ScanMaxDepth < 0 {
+		errs = append(errs, fmt.Errorf("maxDepth cannot be negative"))
+	}
+
+	if c.ScanMaxFiles < 0 {
+		errs = append(errs, fmt.Errorf("maxFiles cannot be negative"))
+	}
+
+	if c.ScanWorkers < 1 {
+		errs = append(errs, fmt.Errorf("scanWorkers must be at least 1"))
+	}
+
+	if _, err := compileExcludes(c.ScanExclude); err != nil {
+		errs = append(errs, err)
+	}
 
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	if c.UsersFile != "" && c.AccessToken != "" {
+		errs = append(errs, fmt.Errorf("set either usersFile or accessToken, not both"))
 	}
-	return defaultValue
+
+	return errors.Join(errs...)
 }
 
-func getEnvIntOrDefault(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if i, err := strconv.Atoi(value); err == nil {
-			return i
+// Validate checks the settings and creates the working directories
+func (c *Config) Validate() error {
+	if err := c.Check(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, dir := range c.Libraries {
+		if info, err := os.Stat(dir.Path); err != nil {
+			errs = append(errs, fmt.Errorf("library %s: %v", dir.Name, err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("library %s: %s is not a directory", dir.Name, dir.Path))
 		}
 	}
-	return defaultValue
+
+	// Ensure directories exist
+	dirs := []string{c.StaticDir, c.LogDir, c.DataDir}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			errs = append(errs, fmt.Errorf("failed to create directory %s: %v", dir, err))
+		}
+	}
+
+	return errors.Join(errs...)
 }
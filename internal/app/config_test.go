@@ -0,0 +1,51 @@
+// internal/app/config_test.go
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// Check lists errors in the order settings are listed, whatever map or
+// scheduling order the checks would otherwise run in
+func TestCheckErrorOrder(t *testing.T) {
+	config := DefaultConfig()
+	config.StaticDir, config.LogDir, config.DataDir = "", "", ""
+	config.LogLevel, config.ConsoleLogLevel = "loud", "quiet"
+	config.LogFormat, config.ConsoleLogFormat = "xml", "yaml"
+
+	want := config.Check().Error()
+	for i := 0; i < 20; i++ {
+		if got := config.Check().Error(); got != want {
+			t.Fatalf("Check reported\n%s\nafter\n%s", got, want)
+		}
+	}
+}
+
+// Settings are read from GOFINDMYFONTS_ variables, not generic names such as
+// SHARED that other programs set
+func TestSharedModeEnvPrefix(t *testing.T) {
+	t.Setenv(ConfigFileEnv, filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv("LAN", "true")
+	t.Setenv("SHARED", "true")
+	t.Setenv("LIBRARIES", "Ignored=/ignored")
+
+	config, err := LoadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.LAN || config.Shared || len(config.Libraries) != 0 {
+		t.Fatalf("unprefixed variables were applied: lan %v, shared %v, libraries %v", config.LAN, config.Shared, config.Libraries)
+	}
+
+	t.Setenv("GOFINDMYFONTS_LAN", "true")
+	t.Setenv("GOFINDMYFONTS_SHARED", "true")
+	t.Setenv("GOFINDMYFONTS_LIBRARIES", "Fonts=/srv/fonts")
+	config, err = LoadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.LAN || !config.Shared || len(config.Libraries) != 1 || config.Libraries[0].Name != "Fonts" {
+		t.Errorf("prefixed variables were not applied: lan %v, shared %v, libraries %v", config.LAN, config.Shared, config.Libraries)
+	}
+}
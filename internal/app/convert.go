@@ -0,0 +1,250 @@
+// internal/app/convert.go
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+// converters maps a source format to the formats it can be converted to
+var converters = map[string]map[string]func(context.Context, *fontFiles, string, string) (string, error){
+	".ttf": {".woff2": convertToWoff2},
+	".otf": {".woff2": convertToWoff2},
+	".woff2": {".ttf": func(ctx context.Context, files *fontFiles, src, dst string) (string, error) {
+		if err := convertToTTF(ctx, files, src, dst); err != nil {
+			return "", err
+		}
+		return dst, nil
+	}},
+}
+
+// conversionSources lists, for each format that can be produced, the source
+// formats to convert from in order of preference
+var conversionSources = map[string][]string{
+	".woff2": {".ttf", ".otf"},
+	".ttf":   {".woff2"},
+}
+
+// normalizeFormat turns "woff2", ".WOFF2" etc. into ".woff2"
+func normalizeFormat(format string) string {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format != "" && !strings.HasPrefix(format, ".") {
+		format = "." + format
+	}
+	return format
+}
+
+// conversionURL is the download link that converts source to format on first request
+func conversionURL(source, format, name string) string {
+	return fmt.Sprintf("/download?path=%s&format=%s&filename=%s",
+		url.QueryEscape(source),
+		url.QueryEscape(strings.TrimPrefix(format, ".")),
+		url.QueryEscape(name+format))
+}
+
+// cachePath returns where the conversion of source to format is cached. The
+// name is derived from the source path, size and modification time, so fonts
+// with the same name in different directories do not collide and an edited
+// source is converted again.
+func (pg *PreviewGenerator) cachePath(source string, info os.FileInfo, format string) string {
+	key := fmt.Sprintf("%s|%d|%d", source, info.Size(), info.ModTime().UnixNano())
+	sum := sha256.Sum256([]byte(key))
+	base := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	return filepath.Join(pg.config.StaticDir, "converted",
+		fmt.Sprintf("%s-%s%s", base, hex.EncodeToString(sum[:])[:16], format))
+}
+
+// cachedConversion returns the cached conversion of source to format, if there is one
+func (pg *PreviewGenerator) cachedConversion(source, format string) (string, bool) {
+	info, err := pg.files.stat(source)
+	if err != nil {
+		return "", false
+	}
+	path := pg.cachePath(source, info, format)
+	if cached, err := os.Stat(path); err == nil && cached.Size() > 0 {
+		return path, true
+	}
+	return "", false
+}
+
+// Convert returns the path of source converted to format, converting it first
+// if it is not cached. Concurrent calls for the same conversion share a single
+// run of the conversion tool, which is killed once every caller has given up.
+// It reports whether the result came from the cache.
+func (pg *PreviewGenerator) Convert(ctx context.Context, source, format string) (string, bool, error) {
+	sourceFormat := strings.ToLower(filepath.Ext(source))
+	converter, ok := converters[sourceFormat][format]
+	if !ok {
+		return "", false, &FontProcessError{
+			Op:   "convert",
+			Path: source,
+			Err:  fmt.Errorf("cannot convert %s to %s", sourceFormat, format),
+		}
+	}
+
+	info, err := pg.files.stat(source)
+	if err != nil {
+		return "", false, &FontProcessError{Op: "stat", Path: source, Err: err}
+	}
+
+	if err := ensureConvertedDir(pg.config); err != nil {
+		return "", false, &FontProcessError{Op: "create_dirs", Err: err}
+	}
+
+	job := ConversionJob{
+		sourceFile:   source,
+		sourceFormat: sourceFormat,
+		format:       format,
+		outputPath:   pg.cachePath(source, info, format),
+	}
+	result, shared := pg.conversions.do(ctx, pg.ctx, job.outputPath, func(ctx context.Context) flightResult {
+		path, cached, err := pg.runConversion(ctx, job, converter)
+		return flightResult{path: path, cached: cached, err: err}
+	})
+	if shared {
+		logging.DebugContext(ctx, "Joined running conversion", "convert", job.outputPath)
+	}
+	if result.cached {
+		conversionCache.Inc("hit")
+	} else {
+		conversionCache.Inc("miss")
+	}
+	return result.path, result.cached, result.err
+}
+
+// flightResult is the outcome of a conversion shared by all of its callers
+type flightResult struct {
+	path   string
+	cached bool
+	err    error
+}
+
+// flightCall is a conversion in progress
+type flightCall struct {
+	done    chan struct{}
+	result  flightResult
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// flightGroup deduplicates concurrent conversions of the same output file
+type flightGroup struct {
+	mu       sync.Mutex
+	calls    map[string]*flightCall
+	stopping map[string]*flightCall // Cancelled runs that have not exited yet
+	running  sync.WaitGroup
+	closed   bool
+}
+
+// errShuttingDown is returned for conversions requested after Drain
+var errShuttingDown = errors.New("server is shutting down")
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: make(map[string]*flightCall), stopping: make(map[string]*flightCall)}
+}
+
+// do runs fn once per key at a time. The run is bound to parent rather than to
+// any one caller; it is cancelled when every caller's ctx is done, and later
+// callers start a fresh run once the cancelled one has exited. It logs with
+// the logger of the caller that started it, so its records carry that caller's
+// request or job ID. It reports whether the caller joined a run started by
+// someone else.
+func (g *flightGroup) do(ctx, parent context.Context, key string, fn func(context.Context) flightResult) (flightResult, bool) {
+	g.mu.Lock()
+	call, shared := g.calls[key]
+	if !shared {
+		if g.closed {
+			g.mu.Unlock()
+			return flightResult{err: errShuttingDown}, false
+		}
+		runCtx, cancel := context.WithCancel(logging.NewContext(parent, logging.FromContext(ctx)))
+		call = &flightCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+		stopping := g.stopping[key]
+		g.running.Add(1)
+		go func() {
+			defer g.running.Done()
+			// A cancelled run of the same key still owns its output file
+			if stopping != nil {
+				<-stopping.done
+			}
+			call.result = fn(runCtx)
+			cancel()
+			g.mu.Lock()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			if g.stopping[key] == call {
+				delete(g.stopping, key)
+			}
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.result, shared
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+				g.stopping[key] = call
+			}
+		}
+		g.mu.Unlock()
+		return flightResult{err: ctx.Err()}, shared
+	}
+}
+
+// drain refuses new runs and waits for the running ones to finish, or for ctx to be done
+func (g *flightGroup) drain(ctx context.Context) error {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Drain stops new conversions and waits for running ones to finish. If ctx
+// expires first the conversion tools are killed, which takes at most
+// conversionWaitDelay, and the deadline error is returned.
+func (pg *PreviewGenerator) Drain(ctx context.Context) error {
+	err := pg.conversions.drain(ctx)
+	if err == nil {
+		return nil
+	}
+
+	logging.ErrorContext(ctx, "Conversions still running at shutdown deadline, stopping them", "drain", "", err)
+	pg.cancel()
+	grace, cancel := context.WithTimeout(context.Background(), conversionWaitDelay)
+	defer cancel()
+	pg.conversions.drain(grace)
+	return err
+}
@@ -0,0 +1,168 @@
+// internal/app/convert_test.go
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// installFakeTools puts stand-ins for woff2_compress and woff2_decompress on
+// the PATH. Like the real tools they write their output next to the input,
+// named after it with the other extension.
+func installFakeTools(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake conversion tools are shell scripts")
+	}
+	bin := t.TempDir()
+	tools := map[string]string{
+		"woff2_compress":   "#!/bin/sh\ncp \"$1\" \"${1%.*}.woff2\"\n",
+		"woff2_decompress": "#!/bin/sh\ncp \"$1\" \"${1%.woff2}.ttf\"\n",
+	}
+	for name, script := range tools {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// newTestGenerator returns a generator whose working directories are temporary
+func newTestGenerator(t *testing.T) *PreviewGenerator {
+	t.Helper()
+	config := DefaultConfig()
+	dir := t.TempDir()
+	config.StaticDir = filepath.Join(dir, "static")
+	config.LogDir = filepath.Join(dir, "logs")
+	config.DataDir = filepath.Join(dir, "data")
+	if err := os.MkdirAll(config.DataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	pg := NewPreviewGenerator(config)
+	t.Cleanup(pg.Close)
+	return pg
+}
+
+// writeFont writes a stand-in font file; the fake tools only copy bytes
+func writeFont(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("font data for "+filepath.Base(path)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Conversions end up at the cache path. The tools name their output after
+// their input, so the temporary copy must be named after the cache path; a
+// WOFF2 copy named after the original once left Font.ttf behind and failed.
+func TestConvertWritesCachePath(t *testing.T) {
+	installFakeTools(t)
+
+	tests := []struct {
+		source string
+		format string
+	}{
+		{"Font.woff2", ".ttf"},
+		{"Font.ttf", ".woff2"},
+		{"Font.otf", ".woff2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source+"->"+tt.format, func(t *testing.T) {
+			pg := newTestGenerator(t)
+			source := filepath.Join(t.TempDir(), tt.source)
+			writeFont(t, source)
+			info, err := os.Stat(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := pg.cachePath(source, info, tt.format)
+
+			got, cached, err := pg.Convert(context.Background(), source, tt.format)
+			if err != nil {
+				t.Fatalf("Convert: %v", err)
+			}
+			if got != want || cached {
+				t.Fatalf("Convert = %q, cached %v; want %q, not cached", got, cached, want)
+			}
+
+			// Neither the temporary copy nor output under another name is left behind
+			entries, err := os.ReadDir(filepath.Dir(want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Name() != filepath.Base(want) {
+				var names []string
+				for _, entry := range entries {
+					names = append(names, entry.Name())
+				}
+				t.Errorf("converted directory holds %v, want only %s", names, filepath.Base(want))
+			}
+
+			got, cached, err = pg.Convert(context.Background(), source, tt.format)
+			if err != nil || got != want || !cached {
+				t.Errorf("second Convert = %q, cached %v, %v; want %q from the cache", got, cached, err, want)
+			}
+		})
+	}
+}
+
+// A request made right after the last caller of a run gave up starts a fresh
+// run, once the cancelled one has exited, instead of joining the doomed run
+func TestFlightGroupRetryAfterCancel(t *testing.T) {
+	g := newFlightGroup()
+	started := make(chan struct{})
+	exit := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan flightResult, 1)
+	go func() {
+		result, _ := g.do(ctx, context.Background(), "key", func(ctx context.Context) flightResult {
+			close(started)
+			<-ctx.Done()
+			<-exit // The killed tool takes a moment to exit
+			return flightResult{err: ctx.Err()}
+		})
+		first <- result
+	}()
+	<-started
+	cancel()
+	if result := <-first; !errors.Is(result.err, context.Canceled) {
+		t.Fatalf("cancelled caller got %v, want context.Canceled", result.err)
+	}
+
+	retried := make(chan struct{})
+	second := make(chan flightResult, 1)
+	go func() {
+		result, shared := g.do(context.Background(), context.Background(), "key", func(ctx context.Context) flightResult {
+			close(retried)
+			return flightResult{path: "fresh"}
+		})
+		if shared {
+			t.Error("retry joined the cancelled run")
+		}
+		second <- result
+	}()
+
+	select {
+	case <-retried:
+		t.Fatal("retry ran before the cancelled run exited")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(exit)
+
+	select {
+	case result := <-second:
+		if result.err != nil || result.path != "fresh" {
+			t.Errorf("retry got %+v, want a fresh result", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("retry did not finish")
+	}
+}
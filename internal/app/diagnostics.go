@@ -0,0 +1,267 @@
+// internal/app/diagnostics.go
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+	"github.com/bradsec/gofindmyfonts/internal/templates"
+)
+
+// conversionTools are the external programs conversions run
+var conversionTools = []string{"woff2_compress", "woff2_decompress"}
+
+// toolVersionTimeout bounds how long a tool may take to report its version
+const toolVersionTimeout = 2 * time.Second
+
+// versionPattern finds a version number in a tool's output
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// ToolCheck reports whether a conversion tool was found on the PATH
+type ToolCheck struct {
+	Name    string `json:"name"`
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"` // Empty if the tool does not report one
+	Error   string `json:"error,omitempty"`
+}
+
+// DirCheck reports whether a working directory can be written
+type DirCheck struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Writable bool   `json:"writable"`
+	Note     string `json:"note,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// CacheUsage is the size of the converted font cache
+type CacheUsage struct {
+	Path  string `json:"path"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+	Error string `json:"error,omitempty"`
+}
+
+// Size returns the cache size in human readable units
+func (c CacheUsage) Size() string {
+	size := float64(c.Bytes)
+	for _, unit := range []string{"B", "KB", "MB", "GB"} {
+		if size < 1024 || unit == "GB" {
+			if unit == "B" {
+				return fmt.Sprintf("%d B", c.Bytes)
+			}
+			return fmt.Sprintf("%.1f %s", size, unit)
+		}
+		size /= 1024
+	}
+	return ""
+}
+
+// Diagnostics reports the resolved configuration and the state of the
+// environment the server depends on
+type Diagnostics struct {
+	Generated    time.Time       `json:"generated"`
+	GoVersion    string          `json:"goVersion"`
+	OS           string          `json:"os"`
+	Arch         string          `json:"arch"`
+	ConfigFile   string          `json:"configFile,omitempty"`
+	Config       *Config         `json:"config"`
+	ConfigErrors []string        `json:"configErrors,omitempty"`
+	Tools        []ToolCheck     `json:"tools"`
+	Dirs         []DirCheck      `json:"dirs"`
+	Cache        CacheUsage      `json:"cache"`
+	RecentErrors []logging.Entry `json:"recentErrors"` // Newest first
+}
+
+// RunDiagnostics checks the configuration, conversion tools, working
+// directories and cache. recent are ERROR log entries, oldest first.
+func RunDiagnostics(ctx context.Context, config *Config, recent []logging.Entry) *Diagnostics {
+	d := &Diagnostics{
+		Generated:    time.Now(),
+		GoVersion:    runtime.Version(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		Config:       config,
+		RecentErrors: make([]logging.Entry, 0, len(recent)),
+	}
+	if config.FileLoaded {
+		d.ConfigFile = config.File
+	}
+	if err := config.Check(); err != nil {
+		d.ConfigErrors = strings.Split(err.Error(), "\n")
+	}
+
+	for _, tool := range conversionTools {
+		d.Tools = append(d.Tools, checkTool(ctx, tool))
+	}
+	for _, dir := range []struct{ name, path string }{
+		{"staticDir", config.StaticDir},
+		{"logDir", config.LogDir},
+		{"dataDir", config.DataDir},
+	} {
+		d.Dirs = append(d.Dirs, checkDir(dir.name, dir.path))
+	}
+	d.Cache = cacheUsage(filepath.Join(config.StaticDir, "converted"))
+
+	for i := len(recent) - 1; i >= 0; i-- {
+		d.RecentErrors = append(d.RecentErrors, recent[i])
+	}
+	return d
+}
+
+// Problems returns a line for each failed check
+func (d *Diagnostics) Problems() []string {
+	problems := append([]string(nil), d.ConfigErrors...)
+	for _, tool := range d.Tools {
+		if tool.Error != "" {
+			problems = append(problems, fmt.Sprintf("%s: %s", tool.Name, tool.Error))
+		}
+	}
+	for _, dir := range d.Dirs {
+		if !dir.Writable {
+			problems = append(problems, fmt.Sprintf("%s %s is not writable: %s", dir.Name, dir.Path, dir.Error))
+		}
+	}
+	return problems
+}
+
+// ConfigJSON returns the configuration as indented JSON, with secrets redacted
+func (d *Diagnostics) ConfigJSON() string {
+	data, err := json.MarshalIndent(d.Config, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// checkTool finds a tool on the PATH and asks it for its version
+func checkTool(ctx context.Context, name string) ToolCheck {
+	check := ToolCheck{Name: name}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		check.Error = "not found on PATH; install Google's woff2 tools to enable conversions"
+		return check
+	}
+	check.Path = path
+
+	ctx, cancel := context.WithTimeout(ctx, toolVersionTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, "--version")
+	cmd.Dir = os.TempDir()
+	output, _ := cmd.CombinedOutput()
+	firstLine, _, _ := strings.Cut(string(output), "\n")
+	check.Version = versionPattern.FindString(firstLine)
+	return check
+}
+
+// checkDir reports whether a file can be created in dir. A directory that does
+// not exist yet is writable if it can be created, as the server does on start.
+func checkDir(name, dir string) DirCheck {
+	check := DirCheck{Name: name, Path: dir}
+	if abs, err := filepath.Abs(dir); err == nil {
+		check.Path = abs
+	}
+
+	target := check.Path
+	info, err := os.Stat(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		check.Note = "does not exist yet; created on start"
+		for errors.Is(err, fs.ErrNotExist) && filepath.Dir(target) != target {
+			target = filepath.Dir(target)
+			info, err = os.Stat(target)
+		}
+	}
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	if !info.IsDir() {
+		check.Error = target + " is not a directory"
+		return check
+	}
+
+	file, err := os.CreateTemp(target, ".gofindmyfonts-check-*")
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	file.Close()
+	os.Remove(file.Name())
+	check.Writable = true
+	return check
+}
+
+// cacheUsage adds up the files in the converted font cache
+func cacheUsage(dir string) CacheUsage {
+	usage := CacheUsage{Path: dir}
+	if abs, err := filepath.Abs(dir); err == nil {
+		usage.Path = abs
+	}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				usage.Files++
+				usage.Bytes += info.Size()
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		usage.Error = err.Error()
+	}
+	return usage
+}
+
+// isLoopbackRequest reports whether a request came from this machine
+func isLoopbackRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// handleDiagnostics serves the diagnostics page, or the report as JSON at
+// /api/diagnostics. In shared mode it is only served to this machine, as it
+// shows server paths and settings.
+func (s *Server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.config.Shared && !isLoopbackRequest(r) {
+		logging.InfoContext(r.Context(), "Diagnostics requested from another machine", "handle_diagnostics", r.RemoteAddr)
+		http.Error(w, "Diagnostics are only available on the server", http.StatusForbidden)
+		return
+	}
+
+	report := RunDiagnostics(r.Context(), s.config, logging.RecentErrors())
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := templates.RenderDiagnostics(w, report); err != nil {
+		logging.ErrorContext(r.Context(), "Error rendering diagnostics", "handle_diagnostics", "", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,205 @@
+// internal/app/discover.go
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+// fontGroup is the files found so far for one font, grouped by base name, and
+// the latest description of them
+type fontGroup struct {
+	name    string
+	sources map[string]string // Found files by extension
+	variant *FontVariant      // Latest description; nil until first described
+	jobs    []ConversionJob   // Conversions offered by the latest description
+	running bool              // A description is queued or running
+	dirty   bool              // Files were found since the running description started
+}
+
+// fontDiscovery describes fonts while the walk that finds them is still
+// running. Each font is described on the walk's worker pool as soon as its
+// first file is found: its identity is hashed, its metadata read and missing
+// formats offered. A font is described again when a later file changes it,
+// one description at a time, so the latest files always win.
+type fontDiscovery struct {
+	pg        *PreviewGenerator
+	ctx       context.Context
+	pool      workerPool
+	described func(variant *FontVariant, first bool, total int)
+	wg        sync.WaitGroup
+
+	mu     sync.Mutex
+	groups map[string]*fontGroup // By base name
+}
+
+// discoverFonts walks root for the font files the policy allows and groups
+// them by base name. described is called with each font as soon as it has been
+// described, with first set the first time, and again whenever a later file
+// changes it; calls for one font never overlap. total is the number of fonts
+// found so far. Files arrive in no particular
+// order, so when two directories hold the same file name the lexically last
+// path wins, as it did with the sorted walk. Once the walk and every
+// description have finished it returns the fonts by name, the conversions
+// they were offered and whether the policy's file limit cut the scan short.
+func (pg *PreviewGenerator) discoverFonts(ctx context.Context, root string, policy ScanPolicy, described func(variant *FontVariant, first bool, total int)) (map[string]*FontVariant, []ConversionJob, bool, error) {
+	logging.DebugContext(ctx, "Starting font search", "find_fonts", root)
+
+	describeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	d := &fontDiscovery{
+		pg:        pg,
+		ctx:       describeCtx,
+		pool:      newWorkerPool(policy.Workers),
+		described: described,
+		groups:    make(map[string]*fontGroup),
+	}
+
+	truncated, err := walkFonts(ctx, root, policy, walkOptions{readDir: pg.readDir, pool: d.pool, archives: pg.files}, d.add)
+	if err != nil {
+		cancel()
+		d.wg.Wait()
+		logging.ErrorContext(ctx, "Error walking directory", "find_fonts", root, err)
+		var processErr *FontProcessError
+		if errors.As(err, &processErr) {
+			return nil, nil, false, err
+		}
+		return nil, nil, false, &FontProcessError{
+			Op:   "walk",
+			Path: root,
+			Err:  fmt.Errorf("error walking directory: %w", err),
+		}
+	}
+	d.wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, nil, false, ctx.Err()
+	}
+	if len(d.groups) == 0 {
+		logging.ErrorContext(ctx, "No fonts found", "find_fonts", root, fmt.Errorf("no font files found"))
+		return nil, nil, false, &FontProcessError{
+			Op:   "scan",
+			Path: root,
+			Err:  fmt.Errorf("no font files found in directory"),
+		}
+	}
+
+	fonts := make(map[string]*FontVariant, len(d.groups))
+	var jobs []ConversionJob
+	for name, group := range d.groups {
+		fonts[name] = group.variant
+		jobs = append(jobs, group.jobs...)
+	}
+	logging.InfoContext(ctx, fmt.Sprintf("Found %d fonts", len(fonts)), "find_fonts", root)
+	return fonts, jobs, truncated, nil
+}
+
+// add groups a file found by the walk and has its font described
+func (d *fontDiscovery) add(path string) {
+	ext := strings.ToLower(filepath.Ext(path))
+	baseName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	group, exists := d.groups[baseName]
+	if !exists {
+		group = &fontGroup{name: baseName, sources: make(map[string]string)}
+		d.groups[baseName] = group
+	}
+	if existing := group.sources[ext]; existing > path {
+		return
+	}
+	group.sources[ext] = path
+	logging.DebugContext(d.ctx, fmt.Sprintf("Found font: %s (%s)", baseName, ext), "find_fonts", path)
+
+	if group.running {
+		group.dirty = true
+		return
+	}
+	group.running = true
+	d.wg.Add(1)
+	go d.run(group)
+}
+
+// run describes group until no more files have been found for it
+func (d *fontDiscovery) run(group *fontGroup) {
+	defer d.wg.Done()
+	for {
+		d.mu.Lock()
+		sources := make(map[string]string, len(group.sources))
+		for ext, path := range group.sources {
+			sources[ext] = path
+		}
+		group.dirty = false
+		d.mu.Unlock()
+
+		if !d.pool.acquire(d.ctx) {
+			d.mu.Lock()
+			group.running = false
+			d.mu.Unlock()
+			return
+		}
+		variant, jobs := d.describe(group.name, sources)
+		d.pool.release()
+
+		d.mu.Lock()
+		first := group.variant == nil
+		group.variant = variant
+		group.jobs = jobs
+		total := len(d.groups)
+		d.mu.Unlock()
+
+		if d.described != nil {
+			d.described(variant, first, total)
+		}
+
+		d.mu.Lock()
+		if !group.dirty || d.ctx.Err() != nil {
+			group.running = false
+			d.mu.Unlock()
+			return
+		}
+		d.mu.Unlock()
+	}
+}
+
+// describe builds the variant for a font from the files found for it
+func (d *fontDiscovery) describe(name string, sources map[string]string) (*FontVariant, []ConversionJob) {
+	variant := &FontVariant{
+		Name:      name,
+		Location:  make(map[string]string),
+		Sources:   sources,
+		Converted: make(map[string]string),
+		Status:    make(map[string]FormatStatus),
+	}
+	for ext, path := range sources {
+		variant.Location[ext] = "/download?path=" + url.QueryEscape(path)
+		variant.Status[ext] = FormatStatus{State: FormatOriginal}
+	}
+	for _, ext := range previewPriority {
+		if location, ok := variant.Location[ext]; ok {
+			variant.PreviewPath = location
+			break
+		}
+	}
+
+	id, err := fontIdentity(d.pg.files, identitySource(variant))
+	if err != nil {
+		logging.WarnContext(d.ctx, "Failed to compute font identity", "find_fonts", name, err)
+	}
+	variant.ID = id
+
+	// Missing formats are offered as on-demand conversions
+	jobs := d.pg.offerConversions(variant)
+
+	// Originals can be previewed straight away, before any conversion runs
+	readFontInfo(d.ctx, d.pg.files, variant)
+	return variant, jobs
+}
@@ -0,0 +1,132 @@
+// internal/app/discover_test.go
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Identities match hashing each font's identity source, including when the
+// preferred source is found after another file of the font
+func TestDiscoverFontsIdentity(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"woff2/Sans.woff2", "ttf/Sans.ttf",
+		"Serif.otf", "Serif.ttf",
+		"a/Mono.woff", "b/Mono.woff",
+	} {
+		writeFont(t, filepath.Join(root, filepath.FromSlash(name)))
+	}
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			pg := newTestGenerator(t)
+			policy := ScanPolicy{Workers: workers}
+			fonts, _, _, err := pg.discoverFonts(context.Background(), root, policy, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{
+				"Sans":  "ttf/Sans.ttf",
+				"Serif": "Serif.ttf",
+				"Mono":  "b/Mono.woff",
+			}
+			for name, source := range want {
+				variant, ok := fonts[name]
+				if !ok {
+					t.Fatalf("%s not found", name)
+				}
+				id, err := fontIdentity(pg.files, filepath.Join(root, filepath.FromSlash(source)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if variant.ID != id {
+					t.Errorf("%s has ID %s, want %s from %s", name, variant.ID, id, source)
+				}
+			}
+		})
+	}
+}
+
+// blockingReadDir returns a directory reader that waits for release before
+// listing directories named slow
+func blockingReadDir(release <-chan struct{}) dirReader {
+	return func(name string) ([]os.DirEntry, error) {
+		if filepath.Base(name) == "slow" {
+			<-release
+		}
+		return os.ReadDir(name)
+	}
+}
+
+// Fonts are described while a slow directory is still being read, and
+// described again when a later file changes them
+func TestDiscoverFontsBeforeWalkEnds(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"Sans.ttf", "slow/Sans.woff2", "slow/Late.ttf"} {
+		writeFont(t, filepath.Join(root, filepath.FromSlash(name)))
+	}
+
+	release := make(chan struct{})
+	pg := newTestGenerator(t)
+	pg.readDir = blockingReadDir(release)
+
+	var mu sync.Mutex
+	var events []string
+	firstFont := make(chan string, 1)
+	described := func(variant *FontVariant, first bool, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, fmt.Sprintf("%s first=%t", variant.Name, first))
+		select {
+		case firstFont <- variant.Name:
+		default:
+		}
+	}
+
+	type result struct {
+		fonts map[string]*FontVariant
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		fonts, _, _, err := pg.discoverFonts(context.Background(), root, ScanPolicy{Workers: 4}, described)
+		done <- result{fonts, err}
+	}()
+
+	select {
+	case name := <-firstFont:
+		if name != "Sans" {
+			t.Errorf("first font described is %s, want Sans", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no font described while the slow directory was blocked")
+	}
+	close(release)
+
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if len(r.fonts) != 2 {
+		t.Errorf("found %d fonts, want 2", len(r.fonts))
+	}
+	if sans := r.fonts["Sans"]; sans == nil || len(sans.Sources) != 2 {
+		t.Errorf("Sans is %+v, want both of its files", sans)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]bool{"Sans first=true": true, "Sans first=false": true, "Late first=true": true}
+	for _, event := range events {
+		delete(want, event)
+	}
+	if len(want) > 0 {
+		t.Errorf("described %v, missing %v", events, want)
+	}
+}
@@ -3,43 +3,114 @@ package app
 
 import (
 	"archive/zip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/bradsec/gofindmyfonts/internal/logging"
 )
 
+// DownloadAllRequest names the fonts to zip by the IDs a scan reported. The
+// server resolves their files from its record of that scan.
+type DownloadAllRequest struct {
+	Job     string   `json:"job"`               // Scan the IDs come from; empty for the most recent scan
+	Fonts   []string `json:"fonts"`             // Font IDs
+	Formats []string `json:"formats,omitempty"` // Formats to include; empty for every format
+}
+
+// ManifestEntry is a font format that was included in the zip or skipped
+type ManifestEntry struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Format string `json:"format,omitempty"`
+	File   string `json:"file,omitempty"` // Entry in the zip
+	Size   int64  `json:"size,omitempty"`
+	Reason string `json:"reason,omitempty"` // Why it was skipped
+}
+
+// DownloadManifest is written to manifest.json in every zip
+type DownloadManifest struct {
+	Job      string          `json:"job"`
+	Created  time.Time       `json:"created"`
+	Included []ManifestEntry `json:"included"`
+	Skipped  []ManifestEntry `json:"skipped"`
+}
+
+// resolveDownload returns the file to send for a format of a font: the
+// original, or its conversion, converted now if it is not cached
+func (s *Server) resolveDownload(ctx context.Context, font registeredFont, format string) (string, error) {
+	if path, ok := font.Sources[format]; ok {
+		return path, nil
+	}
+	if status := font.Status[format]; status.State == FormatFailed {
+		return "", fmt.Errorf("conversion failed: %s", status.Reason)
+	}
+	for _, sourceFormat := range conversionSources[format] {
+		if source, ok := font.Sources[sourceFormat]; ok {
+			path, _, err := s.generator.Convert(ctx, source, format)
+			if err != nil {
+				return "", fmt.Errorf("conversion failed: %v", err)
+			}
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("format not available")
+}
+
+// uniqueEntryName returns name, or name with a numeric suffix if it is already
+// in the zip. The name is shortened to make room for the suffix.
+func uniqueEntryName(name string, used map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; used[name]; i++ {
+		suffix := fmt.Sprintf("-%d%s", i, ext)
+		trimmed := base
+		for len(trimmed)+len(suffix) > maxFilenameBytes || !utf8.ValidString(trimmed) {
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+		name = trimmed + suffix
+	}
+	used[name] = true
+	return name
+}
+
 func (s *Server) handleDownloadAll(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Parse the JSON request containing font URLs
-	var request struct {
-		Fonts []struct {
-			Name    string            `json:"name"`
-			Formats map[string]string `json:"formats"`
-		} `json:"fonts"`
+	var request DownloadAllRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logging.ErrorContext(r.Context(), "Failed to decode request", "download_all", "", err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		logging.Error("Failed to decode request", "download_all", "", err)
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	job, fonts, ok := s.generator.registry.lookup(request.Job)
+	if !ok {
+		logging.InfoContext(r.Context(), "Download requested for unknown scan", "download_all", request.Job)
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Scan not found, please scan again"})
 		return
 	}
 
+	wanted := make(map[string]bool)
+	for _, format := range request.Formats {
+		wanted[normalizeFormat(format)] = true
+	}
+
 	// Create temporary directory for zip creation
 	tempDir, err := os.MkdirTemp("", "fontdownload-*")
 	if err != nil {
-		logging.Error("Failed to create temp directory", "download_all", "", err)
+		logging.ErrorContext(r.Context(), "Failed to create temp directory", "download_all", "", err)
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
@@ -50,113 +121,145 @@ func (s *Server) handleDownloadAll(w http.ResponseWriter, r *http.Request) {
 	zipPath := filepath.Join(tempDir, fmt.Sprintf("fonts-%s.zip", timestamp))
 	zipFile, err := os.Create(zipPath)
 	if err != nil {
-		logging.Error("Failed to create zip file", "download_all", zipPath, err)
+		logging.ErrorContext(r.Context(), "Failed to create zip file", "download_all", zipPath, err)
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
 	defer zipFile.Close()
-
-	// Check for existing files in the zip before adding
-	existingFiles := make(map[string]bool)
 	zipWriter := zip.NewWriter(zipFile)
 	defer zipWriter.Close()
 
-	// Process each font
-	for _, font := range request.Fonts {
-		for format, encodedPath := range font.Formats {
-			// Extract the actual path from the download URL
-			u, err := url.Parse(encodedPath)
-			if err != nil {
-				logging.Error("Failed to parse URL", "download_all", encodedPath, err)
-				continue
-			}
+	manifest := DownloadManifest{Job: job, Created: time.Now().UTC(), Included: []ManifestEntry{}, Skipped: []ManifestEntry{}}
+	usedNames := map[string]bool{"manifest.json": true}
 
-			// Extract the path parameter
-			pathParam := u.Query().Get("path")
-			if pathParam == "" {
-				logging.Error("No path parameter found", "download_all", encodedPath, fmt.Errorf("empty path"))
-				continue
-			}
+	// Files added to the zip, recorded in the audit log once it is sent
+	var delivered []AuditEntry
 
-			// Decode the path
-			decodedPath, err := url.QueryUnescape(pathParam)
-			if err != nil {
-				logging.Error("Failed to decode path", "download_all", pathParam, err)
-				continue
-			}
+	// addFile copies one font file into the zip
+	addFile := func(path, entryName string) (int64, error) {
+		if !s.fontPathAllowed(path) {
+			return 0, fmt.Errorf("outside the shared libraries")
+		}
+		file, _, err := s.generator.files.open(path)
+		if err != nil {
+			return 0, fmt.Errorf("file not readable: %v", err)
+		}
+		defer file.Close()
+		entry, err := zipWriter.CreateHeader(&zip.FileHeader{Name: entryName, Method: zip.Deflate, Modified: manifest.Created})
+		if err != nil {
+			return 0, err
+		}
+		return io.Copy(entry, file)
+	}
 
-			// Attempt to handle both original and converted paths
-			potentialPaths := []string{
-				decodedPath,                     // Original decoded path
-				filepath.Clean(decodedPath),     // Cleaned path
-				filepath.Join(".", decodedPath), // Relative to current directory
-				filepath.Join(s.config.StaticDir, filepath.Base(decodedPath)),              // In static directory
-				filepath.Join(s.config.StaticDir, "converted", filepath.Base(decodedPath)), // In converted directory
-			}
+	requested := make(map[string]bool)
+	for _, id := range request.Fonts {
+		if requested[id] {
+			continue
+		}
+		requested[id] = true
+		matches, ok := fonts[id]
+		if !ok {
+			manifest.Skipped = append(manifest.Skipped, ManifestEntry{ID: id, Reason: "not found in scan"})
+			continue
+		}
 
-			var fontFile *os.File
-			var foundPath string
-			for _, path := range potentialPaths {
-				logging.Info("Trying path", "download_all", path)
-				if file, err := os.Open(path); err == nil {
-					fontFile = file
-					foundPath = path
-					break
+		// Byte-identical fonts share an ID, so every one of them is included
+		for _, font := range matches {
+			formats := make([]string, 0, len(font.Status))
+			for format := range font.Status {
+				if len(wanted) == 0 || wanted[format] {
+					formats = append(formats, format)
 				}
 			}
+			sort.Strings(formats)
 
-			if fontFile == nil {
-				logging.Error("Failed to open font file", "download_all", "Could not find file in any location",
-					fmt.Errorf("paths tried: %v", potentialPaths))
-				continue
-			}
-			defer fontFile.Close()
+			for _, format := range formats {
+				item := ManifestEntry{ID: id, Name: font.Name, Format: format}
 
-			// Create a clean, unique filename for the zip entry
-			sanitizedName := strings.ReplaceAll(font.Name, " ", "_")
-			zipEntryName := fmt.Sprintf("%s%s", sanitizedName, format)
-
-			// Check if the file already exists in the zip
-			if existingFiles[zipEntryName] {
-				continue
-			}
-			existingFiles[zipEntryName] = true
+				path, err := s.resolveDownload(r.Context(), font, format)
+				if err != nil {
+					if r.Context().Err() != nil {
+						logging.InfoContext(r.Context(), "Download cancelled", "download_all", "")
+						return
+					}
+					item.Reason = err.Error()
+					manifest.Skipped = append(manifest.Skipped, item)
+					continue
+				}
 
-			// Create zip entry
-			zipEntry, err := zipWriter.Create(zipEntryName)
-			if err != nil {
-				logging.Error("Failed to create zip entry", "download_all", zipEntryName, err)
-				continue
+				// The name comes from the font file, so it may not add path elements
+				name := sanitizeFilename(strings.ReplaceAll(font.Name, " ", "_")+format, "font"+format)
+				item.File = uniqueEntryName(name, usedNames)
+				written, err := addFile(path, item.File)
+				if err != nil {
+					logging.ErrorContext(r.Context(), "Failed to add font to zip", "download_all", path, err)
+					item.File = ""
+					item.Reason = err.Error()
+					manifest.Skipped = append(manifest.Skipped, item)
+					continue
+				}
+				item.Size = written
+				manifest.Included = append(manifest.Included, item)
+				delivered = append(delivered, AuditEntry{Path: path, Name: item.File, Bytes: written})
+				logging.DebugContext(r.Context(), "Added to zip", "download_all", fmt.Sprintf("File: %s, Entry: %s", path, item.File))
 			}
+		}
+	}
 
-			// Copy font file to zip
-			if _, err := io.Copy(zipEntry, fontFile); err != nil {
-				logging.Error("Failed to copy font to zip", "download_all", zipEntryName, err)
-				continue
-			}
+	if len(manifest.Included) == 0 {
+		logging.InfoContext(r.Context(), "Nothing to download", "download_all", job)
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":    "None of the selected fonts could be included",
+			"manifest": manifest,
+		})
+		return
+	}
 
-			logging.Info("Added to zip", "download_all", fmt.Sprintf("File: %s, Entry: %s", foundPath, zipEntryName))
-		}
+	manifestEntry, err := zipWriter.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: manifest.Created})
+	if err == nil {
+		encoder := json.NewEncoder(manifestEntry)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(manifest)
+	}
+	if err != nil {
+		logging.ErrorContext(r.Context(), "Failed to write manifest", "download_all", zipPath, err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
 	}
 
 	// Close the zip writer before sending
-	zipWriter.Close()
+	if err := zipWriter.Close(); err != nil {
+		logging.ErrorContext(r.Context(), "Failed to finish zip file", "download_all", zipPath, err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
 
 	// Read the zip file
 	zipData, err := os.ReadFile(zipPath)
 	if err != nil {
-		logging.Error("Failed to read zip file", "download_all", zipPath, err)
+		logging.ErrorContext(r.Context(), "Failed to read zip file", "download_all", zipPath, err)
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
 
 	// Set response headers
 	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="fonts-%s.zip"`, timestamp))
+	w.Header().Set("Content-Disposition", contentDisposition(fmt.Sprintf("fonts-%s.zip", timestamp)))
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(zipData)))
+	w.Header().Set("X-Fonts-Skipped", fmt.Sprintf("%d", len(manifest.Skipped)))
 
 	// Send the zip file
-	if _, err := w.Write(zipData); err != nil {
-		logging.Error("Failed to send zip file", "download_all", "", err)
+	zipSize.Observe(float64(len(zipData)))
+	written, err := w.Write(zipData)
+	downloadBytes.Add(float64(written), "zip")
+	if err != nil {
+		logging.ErrorContext(r.Context(), "Failed to send zip file", "download_all", "", err)
+		return
+	}
+	downloadsTotal.Inc("zip")
+	for _, entry := range delivered {
+		s.audit.Record(r, "download_all", entry.Path, entry.Name, entry.Bytes)
 	}
+	logging.InfoContext(r.Context(), fmt.Sprintf("Sent zip with %d files, %d skipped", len(manifest.Included), len(manifest.Skipped)), "download_all", job)
 }
@@ -0,0 +1,76 @@
+// internal/app/download_test.go
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// Byte-identical fonts at different paths share an ID; download-all includes
+// every one of them under its own name
+func TestDownloadAllIdenticalFonts(t *testing.T) {
+	s := newTestServer(t)
+	root := t.TempDir()
+	for _, name := range []string{"a/Sans.ttf", "b/Serif.ttf"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("identical font data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	job, ctx, err := s.generator.StartJob(context.Background(), "identical", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.generator.ProcessFonts(ctx, root, ScanPolicy{Workers: 2}, nil)
+	s.generator.FinishJob(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Fonts) != 2 || result.Fonts[0].ID != result.Fonts[1].ID {
+		t.Fatalf("scan found %+v, want two fonts with the same ID", result.Fonts)
+	}
+
+	id := result.Fonts[0].ID
+	body, err := json.Marshal(DownloadAllRequest{Job: "identical", Fonts: []string{id, id}, Formats: []string{".ttf"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/download-all", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	s.handleDownloadAll(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("download-all returned %d: %s", w.Code, w.Body.String())
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	want := []string{"Sans.ttf", "Serif.ttf", "manifest.json"}
+	if len(names) != len(want) {
+		t.Fatalf("zip has %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("zip has %v, want %v", names, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// internal/app/etag.go
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// etagCacheSize bounds the number of remembered content hashes
+const etagCacheSize = 4096
+
+// etagEntry is the content hash of a file as it was when hashed
+type etagEntry struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// etagCache remembers the content hashes of served files, so a file is only
+// hashed again after it changes
+type etagCache struct {
+	mu      sync.Mutex
+	entries map[string]etagEntry
+}
+
+func newETagCache() *etagCache {
+	return &etagCache{entries: make(map[string]etagEntry)}
+}
+
+// get returns a strong ETag for the contents of file. The file offset is
+// left at the end; http.ServeContent seeks before reading.
+func (c *etagCache) get(file io.Reader, path string, info os.FileInfo) (string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.etag, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil))[:32] + `"`
+
+	c.mu.Lock()
+	if len(c.entries) >= etagCacheSize {
+		c.entries = make(map[string]etagEntry)
+	}
+	c.entries[path] = etagEntry{size: info.Size(), modTime: info.ModTime(), etag: etag}
+	c.mu.Unlock()
+	return etag, nil
+}
+
+// countingWriter records the status and body size of a response
+type countingWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *countingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *countingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,250 @@
+// internal/app/events.go
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+// Progress event types sent on /progress
+const (
+	EventScanStarted        = "scan_started"
+	EventFontFound          = "font_found"
+	EventConversionProgress = "conversion_progress"
+	EventConversionFailed   = "conversion_failed"
+	EventScanComplete       = "scan_complete"
+)
+
+const (
+	progressHistorySize    = 256              // Events kept for Last-Event-ID resume
+	progressSubscriberSize = 64               // Events buffered per /progress connection
+	progressHeartbeat      = 15 * time.Second // Interval of keep-alive comments
+	progressRetry          = 3 * time.Second  // Reconnect delay suggested to EventSource
+)
+
+// ProgressEvent is a typed progress event. Data is sent as the JSON payload.
+type ProgressEvent struct {
+	ID   uint64
+	Job  string
+	Type string
+	Data interface{}
+}
+
+// ScanStarted is the payload of scan_started
+type ScanStarted struct {
+	Job     string `json:"job"`
+	FontDir string `json:"fontDir"`
+}
+
+// FontFound is the payload of font_found. Found counts the fonts read so far
+// and Total the fonts found so far, which grows while the walk is running.
+type FontFound struct {
+	Job   string `json:"job"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Found int    `json:"found"`
+	Total int    `json:"total"`
+}
+
+// ConversionFailed is the payload of conversion_failed
+type ConversionFailed struct {
+	Job    string `json:"job"`
+	Font   string `json:"font"`
+	Format string `json:"format"`
+	Error  string `json:"error"`
+}
+
+// ScanComplete is the payload of scan_complete. Error is set if the scan failed or was cancelled.
+type ScanComplete struct {
+	Job     string       `json:"job"`
+	Summary *ScanSummary `json:"summary,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// progressSubscriber is a /progress connection, optionally limited to one job
+type progressSubscriber struct {
+	job    string
+	events chan ProgressEvent
+}
+
+// progressHub fans progress events out to /progress connections and keeps the
+// most recent ones so a reconnecting client can resume after Last-Event-ID
+type progressHub struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []ProgressEvent
+	subscribers map[*progressSubscriber]struct{}
+	closed      bool
+}
+
+func newProgressHub() *progressHub {
+	return &progressHub{subscribers: make(map[*progressSubscriber]struct{})}
+}
+
+// publish records an event and sends it to every matching subscriber. A
+// subscriber that cannot keep up is disconnected rather than blocking the
+// scan; its client reconnects and resumes from the history.
+func (h *progressHub) publish(job, eventType string, data interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+
+	h.nextID++
+	event := ProgressEvent{ID: h.nextID, Job: job, Type: eventType, Data: data}
+	h.history = append(h.history, event)
+	if len(h.history) > progressHistorySize {
+		h.history = h.history[len(h.history)-progressHistorySize:]
+	}
+
+	for sub := range h.subscribers {
+		if sub.job != "" && sub.job != job {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			logging.Warn("Progress subscriber too slow, disconnecting", "progress", eventType, nil)
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// subscribe registers a subscriber for job ("" for all jobs) and returns the
+// recorded events it missed: those after lastID, or if lastID is 0 and a job
+// is given, everything recorded for that job so far.
+func (h *progressHub) subscribe(job string, lastID uint64) (*progressSubscriber, []ProgressEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &progressSubscriber{job: job, events: make(chan ProgressEvent, progressSubscriberSize)}
+	if h.closed {
+		close(sub.events)
+		return sub, nil
+	}
+	h.subscribers[sub] = struct{}{}
+
+	var backlog []ProgressEvent
+	if lastID > 0 || job != "" {
+		for _, event := range h.history {
+			if event.ID > lastID && (job == "" || event.Job == job) {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+	return sub, backlog
+}
+
+func (h *progressHub) unsubscribe(sub *progressSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// close disconnects every subscriber and drops further events
+func (h *progressHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// writeEvent writes a single server-sent event
+func writeEvent(w http.ResponseWriter, event ProgressEvent) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// handleProgress streams typed progress events. ?job= limits the stream to one
+// scan and replays what that scan already sent; a reconnecting EventSource
+// resumes after its Last-Event-ID.
+func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	rc := http.NewResponseController(w)
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastID = id
+	}
+
+	job := r.URL.Query().Get("job")
+	if job != "" && !validJobID.MatchString(job) {
+		http.Error(w, "Invalid job id", http.StatusBadRequest)
+		return
+	}
+
+	sub, backlog := s.generator.events.subscribe(job, lastID)
+	defer s.generator.events.unsubscribe(sub)
+
+	// The stream outlives the server's write timeout; each write sets its own deadline
+	rc.SetWriteDeadline(time.Now().Add(progressHeartbeat * 2))
+	fmt.Fprintf(w, "retry: %d\n\n", progressRetry.Milliseconds())
+	for _, event := range backlog {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		logging.InfoContext(r.Context(), "Streaming not supported", "handle_progress", "")
+		return
+	}
+
+	heartbeat := time.NewTicker(progressHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			rc.SetWriteDeadline(time.Now().Add(progressHeartbeat * 2))
+			if err := writeEvent(w, event); err != nil {
+				if !isConnectionClosed(err) {
+					logging.ErrorContext(r.Context(), "Error writing progress event", "handle_progress", event.Type, err)
+				}
+				return
+			}
+		case <-heartbeat.C:
+			rc.SetWriteDeadline(time.Now().Add(progressHeartbeat * 2))
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
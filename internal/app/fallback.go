@@ -0,0 +1,82 @@
+// internal/app/fallback.go
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+	"github.com/bradsec/gofindmyfonts/internal/sfnt"
+)
+
+// GenerateFallback computes @font-face overrides that match the local font at
+// fallbackPath to the web font at fontPath
+func GenerateFallback(fontPath, fallbackPath string) (*sfnt.Fallback, error) {
+	files := newFontFiles(DefaultMaxFileSize)
+	defer files.close()
+	return generateFallback(files, fontPath, fallbackPath)
+}
+
+// generateFallback is GenerateFallback reading fonts through files
+func generateFallback(files *fontFiles, fontPath, fallbackPath string) (*sfnt.Fallback, error) {
+	for _, path := range []string{fontPath, fallbackPath} {
+		if !isPathAllowed(path) {
+			return nil, &FontProcessError{Op: "validate", Path: path, Err: fmt.Errorf("not an allowed font file")}
+		}
+	}
+
+	web, err := files.parse(fontPath)
+	if err != nil {
+		return nil, &FontProcessError{Op: "parse", Path: fontPath, Err: err}
+	}
+	fallback, err := files.parse(fallbackPath)
+	if err != nil {
+		return nil, &FontProcessError{Op: "parse", Path: fallbackPath, Err: err}
+	}
+
+	// Fonts without a name table are named after their file
+	family := web.FamilyName()
+	if family == "" {
+		family = strings.TrimSuffix(filepath.Base(fontPath), filepath.Ext(fontPath))
+	}
+
+	result, err := sfnt.MatchFallback(web, fallback, family)
+	if err != nil {
+		return nil, &FontProcessError{Op: "match_fallback", Path: fallbackPath, Err: err}
+	}
+
+	logging.Info(fmt.Sprintf("Generated fallback for %s using %s", result.Family, result.FallbackFamily), "generate_fallback", fontPath)
+	return result, nil
+}
+
+// handleFallback returns fallback overrides for ?font=<path>&fallback=<path>
+func (s *Server) handleFallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fontPath := r.URL.Query().Get("font")
+	fallbackPath := r.URL.Query().Get("fallback")
+	if fontPath == "" || fallbackPath == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Both font and fallback paths are required"})
+		return
+	}
+
+	if !s.fontPathAllowed(fontPath) || !s.fontPathAllowed(fallbackPath) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Fonts must be in a shared library"})
+		return
+	}
+
+	result, err := generateFallback(s.generator.files, filepath.Clean(fontPath), filepath.Clean(fallbackPath))
+	if err != nil {
+		logging.ErrorContext(r.Context(), "Failed to generate fallback", "handle_fallback", fontPath, err)
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{
+			"error": fmt.Sprintf("Unable to generate fallback: %v", err),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
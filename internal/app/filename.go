@@ -0,0 +1,99 @@
+// internal/app/filename.go
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxFilenameBytes keeps names within the limits of common file systems
+const maxFilenameBytes = 200
+
+// windowsReservedNames cannot be used as file names on Windows, with or without an extension
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFilename turns a client-supplied name into a single safe path
+// element. Path separators, control characters such as CR and LF, quotes and
+// characters Windows does not allow become underscores, leading and trailing
+// dots and spaces are dropped so ".." cannot survive, and the name is
+// shortened to maxFilenameBytes keeping its extension. If nothing usable is
+// left, fallback is returned.
+func sanitizeFilename(name, fallback string) string {
+	name = strings.ToValidUTF8(name, "_")
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '_'
+		case unicode.IsControl(r) || r == unicode.ReplacementChar:
+			return '_'
+		case strings.ContainsRune(`"<>:|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, ". ")
+
+	if len(name) > maxFilenameBytes {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		base := name[:maxFilenameBytes-len(ext)]
+		for !utf8.ValidString(base) {
+			base = base[:len(base)-1]
+		}
+		name = strings.TrimRight(base, ". ") + ext
+	}
+
+	stem := strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	if windowsReservedNames[stem] {
+		name = "_" + name
+	}
+
+	if name == "" || strings.Trim(name, "_") == "" {
+		return fallback
+	}
+	return name
+}
+
+// contentDisposition returns an attachment header for name following RFC
+// 6266: a quoted ASCII filename for old clients, and filename* with the
+// UTF-8 name percent-encoded. The name is sanitized first.
+func contentDisposition(name string) string {
+	name = sanitizeFilename(name, "download")
+
+	ascii := strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII {
+			return '_'
+		}
+		return r
+	}, name)
+	if ascii == name {
+		return fmt.Sprintf(`attachment; filename="%s"`, ascii)
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, ascii, encodeRFC5987(name))
+}
+
+// encodeRFC5987 percent-encodes every byte that is not an attr-char
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < utf8.RuneSelf && (unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) || strings.IndexByte("!#$&+-.^_`|~", c) >= 0) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+// internal/app/filename_test.go
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+// hostileNames are font and file names a client or a font file could supply
+var hostileNames = []string{
+	`Font "Bold".ttf`,
+	"Font\r\nX-Injected: yes.ttf",
+	"../../etc/passwd",
+	`..\..\Windows\win.ini`,
+	"..",
+	"con.ttf",
+	strings.Repeat("字", 100) + ".ttf",
+	strings.Repeat("字", 100) + ".ttf", // Needs a suffix to be unique
+	"Fönt Grotesk.otf",
+}
+
+// checkSafeName fails if name could leave its directory or break a header
+func checkSafeName(t *testing.T, input, name string) {
+	t.Helper()
+	if strings.ContainsAny(name, `/\"`) || name == ".." || strings.HasPrefix(name, ".") {
+		t.Errorf("%q: %q can escape its directory or quoting", input, name)
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		t.Errorf("%q: %q contains control characters", input, name)
+	}
+	if !utf8.ValidString(name) || len(name) > maxFilenameBytes {
+		t.Errorf("%q: %q is not valid UTF-8 within %d bytes", input, name, maxFilenameBytes)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Font.ttf", "Font.ttf"},
+		{`Font "Bold".ttf`, "Font _Bold_.ttf"},
+		{"Font\r\nX-Injected: yes.ttf", "Font__X-Injected_ yes.ttf"},
+		{"../../etc/passwd", "_.._etc_passwd"},
+		{`..\..\Windows\win.ini`, "_.._Windows_win.ini"},
+		{"..", "fallback.ttf"},
+		{"", "fallback.ttf"},
+		{"///", "fallback.ttf"},
+		{"con.ttf", "_con.ttf"},
+		{"LPT1", "_LPT1"},
+		{"aux.Font.ttf", "aux.Font.ttf"},
+		// 196 bytes are left before the extension, which ends mid-rune; the partial rune is dropped
+		{strings.Repeat("字", 100) + ".ttf", strings.Repeat("字", 65) + ".ttf"},
+		{"Fönt Grotesk.otf", "Fönt Grotesk.otf"},
+		{"bad\xffutf8.ttf", "bad_utf8.ttf"},
+	}
+	for _, tt := range tests {
+		got := sanitizeFilename(tt.name, "fallback.ttf")
+		if got != tt.want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		checkSafeName(t, tt.name, got)
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Font.ttf", `attachment; filename="Font.ttf"`},
+		{`Font "Bold".ttf`, `attachment; filename="Font _Bold_.ttf"`},
+		{"x.ttf\r\nSet-Cookie: a=b", `attachment; filename="x.ttf__Set-Cookie_ a=b"`},
+		{"../secret.ttf", `attachment; filename="_secret.ttf"`},
+		{`..\secret.ttf`, `attachment; filename="_secret.ttf"`},
+		{"..", `attachment; filename="download"`},
+		{"con.ttf", `attachment; filename="_con.ttf"`},
+		{"Fönt.ttf", `attachment; filename="F_nt.ttf"; filename*=UTF-8''F%C3%B6nt.ttf`},
+		{"日本語 Mincho.otf", `attachment; filename="___ Mincho.otf"; filename*=UTF-8''%E6%97%A5%E6%9C%AC%E8%AA%9E%20Mincho.otf`},
+	}
+	for _, tt := range tests {
+		got := contentDisposition(tt.name)
+		if got != tt.want {
+			t.Errorf("contentDisposition(%q) =\n  %s\nwant\n  %s", tt.name, got, tt.want)
+		}
+		if strings.ContainsAny(got, "\r\n") {
+			t.Errorf("contentDisposition(%q) can split the header: %q", tt.name, got)
+		}
+	}
+}
+
+// Font names come from font files, so download-all must not let them add
+// path elements to zip entries
+func TestDownloadAllHostileNames(t *testing.T) {
+	s := newTestServer(t)
+	dir := t.TempDir()
+
+	variants := make(map[string]*FontVariant)
+	var ids []string
+	for i, name := range hostileNames {
+		path := filepath.Join(dir, "font"+string(rune('a'+i))+".ttf")
+		writeFont(t, path)
+		id := "id" + string(rune('a'+i))
+		variants[id] = &FontVariant{
+			ID:      id,
+			Name:    name,
+			Sources: map[string]string{".ttf": path},
+			Status:  map[string]FormatStatus{".ttf": {State: FormatOriginal}},
+		}
+		ids = append(ids, id)
+	}
+	s.generator.registry.record("job", variants)
+
+	body, err := json.Marshal(DownloadAllRequest{Job: "job", Fonts: ids})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/download-all", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	s.handleDownloadAll(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("download-all returned %d: %s", w.Code, w.Body.String())
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.File) != len(hostileNames)+1 {
+		t.Errorf("zip has %d entries, want %d fonts and the manifest", len(archive.File), len(hostileNames))
+	}
+	for _, f := range archive.File {
+		checkSafeName(t, f.Name, f.Name)
+		if filepath.Base(f.Name) != f.Name || !filepath.IsLocal(f.Name) {
+			t.Errorf("zip entry %q is not a plain file name", f.Name)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// internal/app/fontmetrics.go
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+	"github.com/bradsec/gofindmyfonts/internal/sfnt"
+)
+
+// FontMetricsResponse is the vertical metrics and sanity report of a single font file
+type FontMetricsResponse struct {
+	Name    string              `json:"name"`
+	Metrics *sfnt.Metrics       `json:"metrics"`
+	Report  *sfnt.MetricsReport `json:"report"`
+}
+
+// readFileMetrics parses a font file and reports on its vertical metrics
+func readFileMetrics(files *fontFiles, fontPath string) (*FontMetricsResponse, error) {
+	font, err := files.parse(fontPath)
+	if err != nil {
+		return nil, &FontProcessError{Op: "parse", Path: fontPath, Err: err}
+	}
+	metrics, err := font.Metrics()
+	if err != nil {
+		return nil, &FontProcessError{Op: "read_metrics", Path: fontPath, Err: err}
+	}
+
+	name := strings.TrimSuffix(filepath.Base(fontPath), filepath.Ext(fontPath))
+	return &FontMetricsResponse{
+		Name:    name,
+		Metrics: metrics,
+		Report:  metrics.Report(name),
+	}, nil
+}
+
+// handleFontMetrics returns the vertical metrics report for the font at ?path=
+func (s *Server) handleFontMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fontPath := filepath.Clean(r.URL.Query().Get("path"))
+	if r.URL.Query().Get("path") == "" || !isPathAllowed(fontPath) || !s.fontPathAllowed(fontPath) {
+		logging.InfoContext(r.Context(), "Metrics requested for invalid path", "handle_metrics", fontPath)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid font path"})
+		return
+	}
+
+	response, err := readFileMetrics(s.generator.files, fontPath)
+	if err != nil {
+		logging.ErrorContext(r.Context(), "Failed to read font metrics", "handle_metrics", fontPath, err)
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{
+			"error": fmt.Sprintf("Unable to read metrics: %v", err),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,169 @@
+// internal/app/jobs.go
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+// validJobID restricts client supplied job IDs to short URL-safe tokens
+var validJobID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ScanJob is a running scan that can be cancelled by ID
+type ScanJob struct {
+	ID      string    `json:"id"`
+	FontDir string    `json:"fontDir"`
+	Started time.Time `json:"started"`
+	Owner   string    `json:"owner,omitempty"` // Basic auth user who started the scan
+	cancel  context.CancelFunc
+	log     *slog.Logger // Adds the job ID to records
+}
+
+// jobIDKey is the context key of the ID of the job a scan runs under
+type jobIDKey struct{}
+
+// jobIDFromContext returns the ID of the job ctx belongs to, or "" outside a job
+func jobIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(jobIDKey{}).(string)
+	return id
+}
+
+// jobRegistry tracks running scans by ID
+type jobRegistry struct {
+	mu   sync.Mutex
+	jobs map[string]*ScanJob
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: make(map[string]*ScanJob)}
+}
+
+// StartJob registers a scan bound to parent and to the generator's lifetime. An
+// empty id is replaced with a random one. The job is owned by the basic auth
+// user parent carries, if any. The returned context is cancelled when the job
+// is cancelled, the parent is done or the generator is closed.
+func (pg *PreviewGenerator) StartJob(parent context.Context, id, fontDir string) (*ScanJob, context.Context, error) {
+	if id == "" {
+		id = newJobID()
+	} else if !validJobID.MatchString(id) {
+		return nil, nil, fmt.Errorf("invalid job id")
+	}
+
+	ctx, cancel := context.WithCancel(logging.With(context.WithValue(parent, jobIDKey{}, id), "job_id", id))
+	stop := context.AfterFunc(pg.ctx, cancel)
+
+	job := &ScanJob{
+		ID:      id,
+		FontDir: fontDir,
+		Started: time.Now(),
+		Owner:   contextUser(parent),
+		cancel: func() {
+			stop()
+			cancel()
+		},
+		log: logging.FromContext(ctx),
+	}
+
+	pg.jobs.mu.Lock()
+	defer pg.jobs.mu.Unlock()
+	if _, exists := pg.jobs.jobs[id]; exists {
+		job.cancel()
+		return nil, nil, fmt.Errorf("job %s is already running", id)
+	}
+	pg.jobs.jobs[id] = job
+
+	job.log.Info("Started job", "operation", "start_job", "path", fontDir)
+	return job, ctx, nil
+}
+
+// FinishJob removes a job from the registry and releases its context
+func (pg *PreviewGenerator) FinishJob(job *ScanJob) {
+	pg.jobs.mu.Lock()
+	delete(pg.jobs.jobs, job.ID)
+	pg.jobs.mu.Unlock()
+
+	job.cancel()
+	job.log.Info("Finished job", "operation", "finish_job", "path", job.FontDir,
+		"duration_ms", time.Since(job.Started).Milliseconds())
+}
+
+// CancelJob cancels a running job if allowed, when not nil, accepts it. It
+// reports false if no such job is running or it may not be cancelled.
+func (pg *PreviewGenerator) CancelJob(id string, allowed func(ScanJob) bool) bool {
+	pg.jobs.mu.Lock()
+	job, exists := pg.jobs.jobs[id]
+	pg.jobs.mu.Unlock()
+
+	if !exists || (allowed != nil && !allowed(*job)) {
+		return false
+	}
+	job.cancel()
+	job.log.Info("Cancelled job", "operation", "cancel_job", "path", job.FontDir)
+	return true
+}
+
+// Jobs returns the running jobs allowed accepts, or all of them if allowed is
+// nil, oldest first
+func (pg *PreviewGenerator) Jobs(allowed func(ScanJob) bool) []ScanJob {
+	pg.jobs.mu.Lock()
+	defer pg.jobs.mu.Unlock()
+
+	jobs := make([]ScanJob, 0, len(pg.jobs.jobs))
+	for _, job := range pg.jobs.jobs {
+		if allowed == nil || allowed(*job) {
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Started.Before(jobs[j].Started) })
+	return jobs
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// ownJobs returns the filter for the jobs a request may see and cancel. In
+// shared mode users only see their own scans; without a users file everyone
+// is the same anonymous user.
+func (s *Server) ownJobs(r *http.Request) func(ScanJob) bool {
+	if !s.config.Shared {
+		return nil
+	}
+	user := contextUser(r.Context())
+	return func(job ScanJob) bool {
+		return job.Owner == user
+	}
+}
+
+// handleJobs lists running jobs (GET /api/jobs) and cancels them (DELETE /api/jobs/<id>)
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/jobs"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		writeJSON(w, http.StatusOK, s.generator.Jobs(s.ownJobs(r)))
+	case r.Method == http.MethodDelete && id != "":
+		if !s.generator.CancelJob(id, s.ownJobs(r)) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "Job not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "cancelled", "id": id})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,66 @@
+// internal/app/jobs_test.go
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// In shared mode users only list and cancel the scans they started
+func TestSharedJobsOwnedByUser(t *testing.T) {
+	s := newTestServer(t)
+	fontDir := t.TempDir()
+	scans := make(map[string]context.Context)
+	for _, user := range []string{"alice", "bob"} {
+		ctx := context.WithValue(context.Background(), userKey{}, user)
+		job, jobCtx, err := s.generator.StartJob(ctx, user+"-scan", fontDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.generator.FinishJob(job)
+		scans[user] = jobCtx
+	}
+
+	request := func(method, path, user string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r = r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+		w := httptest.NewRecorder()
+		s.handleJobs(w, r)
+		return w
+	}
+	listed := func(user string) []string {
+		var jobs []ScanJob
+		if err := json.Unmarshal(request(http.MethodGet, "/api/jobs", user).Body.Bytes(), &jobs); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+
+	if ids := listed("alice"); len(ids) != 2 {
+		t.Errorf("outside shared mode alice sees %v, want both scans", ids)
+	}
+
+	s.config.Shared = true
+	if ids := listed("alice"); len(ids) != 1 || ids[0] != "alice-scan" {
+		t.Errorf("alice sees %v, want only alice-scan", ids)
+	}
+	if w := request(http.MethodDelete, "/api/jobs/bob-scan", "alice"); w.Code != http.StatusNotFound {
+		t.Errorf("alice cancelling bob's scan got %d, want 404", w.Code)
+	}
+	if scans["bob"].Err() != nil {
+		t.Error("bob's scan was cancelled by alice")
+	}
+	if ids := listed("bob"); len(ids) != 1 || ids[0] != "bob-scan" {
+		t.Errorf("bob sees %v, want only bob-scan", ids)
+	}
+	if w := request(http.MethodDelete, "/api/jobs/alice-scan", "alice"); w.Code != http.StatusOK || scans["alice"].Err() == nil {
+		t.Errorf("alice cancelling alice-scan got %d, want 200 and a cancelled scan", w.Code)
+	}
+}
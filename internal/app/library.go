@@ -0,0 +1,435 @@
+// internal/app/library.go
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+const libraryVersion = 1
+
+// LibraryEntry holds the user's annotations for a single font
+type LibraryEntry struct {
+	Name     string   `json:"name"`
+	Favorite bool     `json:"favorite,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// Collection is a named list of fonts
+type Collection struct {
+	Name    string    `json:"name"`
+	Fonts   []string  `json:"fonts"`
+	Updated time.Time `json:"updated"`
+}
+
+// Library is the persisted set of favorites, tags and collections, keyed by font ID
+type Library struct {
+	Version     int                      `json:"version"`
+	Fonts       map[string]*LibraryEntry `json:"fonts"`
+	Collections map[string]*Collection   `json:"collections"`
+}
+
+// LibraryStore persists a Library to a local JSON file
+type LibraryStore struct {
+	mu      sync.RWMutex
+	path    string
+	library Library
+}
+
+// NewLibraryStore loads the library stored at path, starting empty if it does not exist
+func NewLibraryStore(path string) (*LibraryStore, error) {
+	ls := &LibraryStore{
+		path:    path,
+		library: newLibrary(),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logging.Info("No library file found, starting empty", "library_load", path)
+			return ls, nil
+		}
+		return nil, fmt.Errorf("failed to read library: %w", err)
+	}
+
+	var library Library
+	if err := json.Unmarshal(data, &library); err != nil {
+		return nil, fmt.Errorf("failed to parse library: %w", err)
+	}
+	ls.library = normalizeLibrary(library)
+
+	logging.Info(fmt.Sprintf("Loaded library with %d fonts and %d collections",
+		len(ls.library.Fonts), len(ls.library.Collections)), "library_load", path)
+	return ls, nil
+}
+
+func newLibrary() Library {
+	return Library{
+		Version:     libraryVersion,
+		Fonts:       make(map[string]*LibraryEntry),
+		Collections: make(map[string]*Collection),
+	}
+}
+
+// normalizeLibrary fills in missing maps and drops invalid entries from a decoded library
+func normalizeLibrary(library Library) Library {
+	result := newLibrary()
+	for id, entry := range library.Fonts {
+		if id == "" || entry == nil {
+			continue
+		}
+		entry.Tags = normalizeTags(entry.Tags)
+		result.Fonts[id] = entry
+	}
+	for name, collection := range library.Collections {
+		name = strings.TrimSpace(name)
+		if name == "" || collection == nil {
+			continue
+		}
+		collection.Name = name
+		if collection.Fonts == nil {
+			collection.Fonts = []string{}
+		}
+		result.Collections[name] = collection
+	}
+	return result
+}
+
+// normalizeTags trims, lowercases, de-duplicates and sorts tags
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Snapshot returns a deep copy of the current library
+func (ls *LibraryStore) Snapshot() Library {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	snapshot := newLibrary()
+	for id, entry := range ls.library.Fonts {
+		copied := *entry
+		copied.Tags = append([]string(nil), entry.Tags...)
+		snapshot.Fonts[id] = &copied
+	}
+	for name, collection := range ls.library.Collections {
+		copied := *collection
+		copied.Fonts = append([]string{}, collection.Fonts...)
+		snapshot.Collections[name] = &copied
+	}
+	return snapshot
+}
+
+// UpdateFont sets the favorite flag and/or tags of a font. Nil arguments are left unchanged.
+func (ls *LibraryStore) UpdateFont(id, name string, favorite *bool, tags []string) error {
+	if id == "" {
+		return fmt.Errorf("font id cannot be empty")
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	entry, exists := ls.library.Fonts[id]
+	if !exists {
+		entry = &LibraryEntry{}
+	}
+	if name != "" {
+		entry.Name = name
+	}
+	if favorite != nil {
+		entry.Favorite = *favorite
+	}
+	if tags != nil {
+		entry.Tags = normalizeTags(tags)
+	}
+
+	ls.library.Fonts[id] = entry
+	ls.prune(id)
+	return ls.save()
+}
+
+// SaveCollection creates or replaces a named collection
+func (ls *LibraryStore) SaveCollection(name string, fonts []LibraryFontRef) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("collection name cannot be empty")
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	var previous []string
+	if existing, exists := ls.library.Collections[name]; exists {
+		previous = existing.Fonts
+	}
+
+	ids := make([]string, 0, len(fonts))
+	seen := make(map[string]bool)
+	for _, font := range fonts {
+		if font.ID == "" || seen[font.ID] {
+			continue
+		}
+		seen[font.ID] = true
+		ids = append(ids, font.ID)
+
+		// Remember the font name so exported collections stay readable
+		entry, exists := ls.library.Fonts[font.ID]
+		if !exists {
+			entry = &LibraryEntry{}
+			ls.library.Fonts[font.ID] = entry
+		}
+		if font.Name != "" {
+			entry.Name = font.Name
+		}
+	}
+
+	ls.library.Collections[name] = &Collection{
+		Name:    name,
+		Fonts:   ids,
+		Updated: time.Now(),
+	}
+	// Fonts taken out of a replaced collection may no longer be needed
+	for _, id := range previous {
+		ls.prune(id)
+	}
+	return ls.save()
+}
+
+// DeleteCollection removes a named collection, and the entries of its fonts
+// that were only kept for it
+func (ls *LibraryStore) DeleteCollection(name string) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.library.Collections[name]
+	if !exists {
+		return fmt.Errorf("collection %q not found", name)
+	}
+	delete(ls.library.Collections, name)
+	for _, id := range collection.Fonts {
+		ls.prune(id)
+	}
+	return ls.save()
+}
+
+// Import merges an exported library into the store, or replaces it entirely
+func (ls *LibraryStore) Import(library Library, replace bool) error {
+	imported := normalizeLibrary(library)
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if replace {
+		ls.library = imported
+		return ls.save()
+	}
+
+	for id, entry := range imported.Fonts {
+		existing, exists := ls.library.Fonts[id]
+		if !exists {
+			ls.library.Fonts[id] = entry
+			continue
+		}
+		if existing.Name == "" {
+			existing.Name = entry.Name
+		}
+		existing.Favorite = existing.Favorite || entry.Favorite
+		existing.Tags = normalizeTags(append(existing.Tags, entry.Tags...))
+	}
+	// Fonts only in a replaced collection are pruned once every collection is in
+	var replaced []string
+	for name, collection := range imported.Collections {
+		if previous, exists := ls.library.Collections[name]; exists {
+			replaced = append(replaced, previous.Fonts...)
+		}
+		ls.library.Collections[name] = collection
+	}
+	for _, id := range replaced {
+		ls.prune(id)
+	}
+	return ls.save()
+}
+
+// prune drops the entry of a font that is not a favorite, has no tags and is
+// in no collection. Callers must hold the lock.
+func (ls *LibraryStore) prune(id string) {
+	entry, exists := ls.library.Fonts[id]
+	if exists && !entry.Favorite && len(entry.Tags) == 0 && !ls.inCollection(id) {
+		delete(ls.library.Fonts, id)
+	}
+}
+
+// inCollection reports whether a font is referenced by any collection. Callers must hold the lock.
+func (ls *LibraryStore) inCollection(id string) bool {
+	for _, collection := range ls.library.Collections {
+		for _, fontID := range collection.Fonts {
+			if fontID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// save writes the library to disk atomically. Callers must hold the lock.
+func (ls *LibraryStore) save() error {
+	data, err := json.MarshalIndent(ls.library, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode library: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ls.path), 0755); err != nil {
+		return fmt.Errorf("failed to create library directory: %w", err)
+	}
+
+	tmpPath := ls.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write library: %w", err)
+	}
+	if err := os.Rename(tmpPath, ls.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace library: %w", err)
+	}
+
+	logging.Info("Library saved", "library_save", ls.path)
+	return nil
+}
+
+// LibraryFontRef identifies a font in library requests
+type LibraryFontRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// handleLibrary returns the current library
+func (s *Server) handleLibrary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.library.Snapshot())
+}
+
+// handleLibraryFont updates the favorite flag and tags of a font
+func (s *Server) handleLibraryFont(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		LibraryFontRef
+		Favorite *bool    `json:"favorite"`
+		Tags     []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logging.ErrorContext(r.Context(), "Failed to decode request", "library_font", "", err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	if err := s.library.UpdateFont(request.ID, request.Name, request.Favorite, request.Tags); err != nil {
+		logging.ErrorContext(r.Context(), "Failed to update font", "library_font", request.ID, err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.library.Snapshot())
+}
+
+// handleLibraryCollections creates, replaces or deletes a collection
+func (s *Server) handleLibraryCollections(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var request struct {
+			Name  string           `json:"name"`
+			Fonts []LibraryFontRef `json:"fonts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			logging.ErrorContext(r.Context(), "Failed to decode request", "library_collections", "", err)
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+			return
+		}
+		if err := s.library.SaveCollection(request.Name, request.Fonts); err != nil {
+			logging.ErrorContext(r.Context(), "Failed to save collection", "library_collections", request.Name, err)
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if err := s.library.DeleteCollection(name); err != nil {
+			logging.ErrorContext(r.Context(), "Failed to delete collection", "library_collections", name, err)
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.library.Snapshot())
+}
+
+// handleLibraryExport sends the library as a downloadable JSON file
+func (s *Server) handleLibraryExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	timestamp := time.Now().Format("20060102-150405")
+	w.Header().Set("Content-Disposition", contentDisposition(fmt.Sprintf("fontlibrary-%s.json", timestamp)))
+	writeJSON(w, http.StatusOK, s.library.Snapshot())
+}
+
+// handleLibraryImport merges (default) or replaces (?mode=replace) the library with uploaded JSON
+func (s *Server) handleLibraryImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var library Library
+	if err := json.NewDecoder(r.Body).Decode(&library); err != nil {
+		logging.ErrorContext(r.Context(), "Failed to decode library import", "library_import", "", err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid library file"})
+		return
+	}
+
+	replace := r.URL.Query().Get("mode") == "replace"
+	if err := s.library.Import(library, replace); err != nil {
+		logging.ErrorContext(r.Context(), "Failed to import library", "library_import", "", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	logging.InfoContext(r.Context(), fmt.Sprintf("Imported library with %d fonts and %d collections",
+		len(library.Fonts), len(library.Collections)), "library_import", "")
+	writeJSON(w, http.StatusOK, s.library.Snapshot())
+}
+
+// writeJSON encodes v as the JSON response body with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil && !isConnectionClosed(err) {
+		logging.Error("Error encoding response", "write_json", "", err)
+	}
+}
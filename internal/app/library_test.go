@@ -0,0 +1,193 @@
+// internal/app/library_test.go
+package app
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func newTestLibrary(t *testing.T) (*LibraryStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "library.json")
+	ls, err := NewLibraryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ls, path
+}
+
+// fontIDs returns the IDs with a library entry, sorted
+func fontIDs(library Library) []string {
+	ids := []string{}
+	for id := range library.Fonts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestLibrarySaveCollection(t *testing.T) {
+	ls, _ := newTestLibrary(t)
+	if err := ls.SaveCollection("  ", nil); err == nil {
+		t.Error("saved a collection without a name")
+	}
+
+	refs := []LibraryFontRef{{ID: "a", Name: "Sans"}, {ID: "b", Name: "Serif"}, {ID: "a"}, {ID: ""}}
+	if err := ls.SaveCollection(" Headings ", refs); err != nil {
+		t.Fatal(err)
+	}
+	library := ls.Snapshot()
+	collection := library.Collections["Headings"]
+	if collection == nil || !reflect.DeepEqual(collection.Fonts, []string{"a", "b"}) {
+		t.Fatalf("collection %+v, want fonts a and b", collection)
+	}
+	if library.Fonts["a"].Name != "Sans" || library.Fonts["b"].Name != "Serif" {
+		t.Errorf("font names not remembered: %+v %+v", library.Fonts["a"], library.Fonts["b"])
+	}
+
+	// Fonts taken out of a replaced collection lose their entries
+	if err := ls.SaveCollection("Headings", []LibraryFontRef{{ID: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fontIDs(ls.Snapshot()); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("entries %v after replacing the collection, want [b]", got)
+	}
+}
+
+func TestLibraryDeleteCollection(t *testing.T) {
+	ls, _ := newTestLibrary(t)
+	favorite := true
+	for _, step := range []error{
+		ls.SaveCollection("Body", []LibraryFontRef{{ID: "only-body"}, {ID: "shared"}, {ID: "favorite"}, {ID: "tagged"}}),
+		ls.SaveCollection("Display", []LibraryFontRef{{ID: "shared"}}),
+		ls.UpdateFont("favorite", "", &favorite, nil),
+		ls.UpdateFont("tagged", "", nil, []string{"serif"}),
+	} {
+		if step != nil {
+			t.Fatal(step)
+		}
+	}
+
+	if err := ls.DeleteCollection("Missing"); err == nil {
+		t.Error("deleted a collection that does not exist")
+	}
+	if err := ls.DeleteCollection("Body"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries kept only for the deleted collection are pruned
+	library := ls.Snapshot()
+	if got, want := fontIDs(library), []string{"favorite", "shared", "tagged"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries %v, want %v", got, want)
+	}
+	if _, exists := library.Collections["Body"]; exists {
+		t.Error("collection not deleted")
+	}
+}
+
+func TestLibraryUpdateFont(t *testing.T) {
+	ls, _ := newTestLibrary(t)
+	favorite, notFavorite := true, false
+	if err := ls.UpdateFont("", "", &favorite, nil); err == nil {
+		t.Error("updated a font without an ID")
+	}
+	if err := ls.UpdateFont("a", "Sans", &favorite, []string{" Serif", "serif", "Bold "}); err != nil {
+		t.Fatal(err)
+	}
+	entry := ls.Snapshot().Fonts["a"]
+	if entry == nil || !entry.Favorite || entry.Name != "Sans" || !reflect.DeepEqual(entry.Tags, []string{"bold", "serif"}) {
+		t.Fatalf("entry %+v", entry)
+	}
+
+	if err := ls.UpdateFont("a", "", &notFavorite, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fontIDs(ls.Snapshot()); len(got) != 0 {
+		t.Errorf("entries %v after clearing every annotation", got)
+	}
+}
+
+func TestLibraryImport(t *testing.T) {
+	ls, _ := newTestLibrary(t)
+	favorite := true
+	if err := ls.UpdateFont("a", "Sans", nil, []string{"sans"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.SaveCollection("Old", []LibraryFontRef{{ID: "dropped"}, {ID: "moved"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := Library{
+		Fonts: map[string]*LibraryEntry{
+			"a":     {Name: "Ignored", Favorite: true, Tags: []string{"Display"}},
+			"moved": {Name: "Moved"},
+			"":      {Name: "No ID"},
+		},
+		Collections: map[string]*Collection{
+			"Old": {Fonts: []string{"a"}},
+			"New": {Fonts: []string{"moved"}},
+			" ":   {Fonts: []string{"a"}},
+		},
+	}
+	if err := ls.Import(imported, false); err != nil {
+		t.Fatal(err)
+	}
+
+	library := ls.Snapshot()
+	if entry := library.Fonts["a"]; !entry.Favorite || entry.Name != "Sans" || !reflect.DeepEqual(entry.Tags, []string{"display", "sans"}) {
+		t.Errorf("merged entry %+v", entry)
+	}
+	if got, want := fontIDs(library), []string{"a", "moved"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries %v, want %v", got, want)
+	}
+	if len(library.Collections) != 2 || library.Collections["New"].Name != "New" {
+		t.Errorf("collections %+v", library.Collections)
+	}
+
+	// Replacing discards everything that was there
+	if err := ls.Import(Library{Fonts: map[string]*LibraryEntry{"z": {Favorite: favorite}}}, true); err != nil {
+		t.Fatal(err)
+	}
+	library = ls.Snapshot()
+	if got := fontIDs(library); !reflect.DeepEqual(got, []string{"z"}) || len(library.Collections) != 0 {
+		t.Errorf("after replace: entries %v, collections %v", got, library.Collections)
+	}
+}
+
+func TestLibraryRoundTrip(t *testing.T) {
+	ls, path := newTestLibrary(t)
+	favorite := true
+	if err := ls.UpdateFont("a", "Sans", &favorite, []string{"sans"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.SaveCollection("Headings", []LibraryFontRef{{ID: "a"}, {ID: "b", Name: "Serif"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := ls.Snapshot()
+
+	reloaded, err := NewLibraryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reloaded.Snapshot()
+	if !reflect.DeepEqual(fontIDs(got), fontIDs(want)) || !reflect.DeepEqual(got.Fonts["a"], want.Fonts["a"]) ||
+		!reflect.DeepEqual(got.Fonts["b"], want.Fonts["b"]) {
+		t.Errorf("fonts changed on reload: %+v, want %+v", got.Fonts, want.Fonts)
+	}
+	saved, loaded := want.Collections["Headings"], got.Collections["Headings"]
+	if loaded == nil || !reflect.DeepEqual(loaded.Fonts, saved.Fonts) || !loaded.Updated.Equal(saved.Updated) {
+		t.Errorf("collection changed on reload: %+v, want %+v", loaded, saved)
+	}
+
+	// An exported library imported into an empty store is the same library
+	fresh, _ := newTestLibrary(t)
+	if err := fresh.Import(got, true); err != nil {
+		t.Fatal(err)
+	}
+	if imported := fresh.Snapshot(); !reflect.DeepEqual(fontIDs(imported), fontIDs(want)) ||
+		!reflect.DeepEqual(imported.Collections["Headings"].Fonts, saved.Fonts) {
+		t.Errorf("import of an export differs: %+v", imported)
+	}
+}
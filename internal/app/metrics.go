@@ -0,0 +1,61 @@
+// internal/app/metrics.go
+package app
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+	"github.com/bradsec/gofindmyfonts/internal/metrics"
+)
+
+// Server metrics, served at /metrics. These are unrelated to the font metrics
+// served at /api/metrics.
+var (
+	scansTotal = metrics.NewCounter("gofindmyfonts_scans_total",
+		"Scans by outcome: completed, failed or cancelled.", "outcome")
+	scanDuration = metrics.NewHistogram("gofindmyfonts_scan_duration_seconds",
+		"Time taken by completed scans, including conversions run during the scan.",
+		metrics.ExponentialBuckets(0.05, 2, 12))
+	fontsFound = metrics.NewCounter("gofindmyfonts_fonts_found_total",
+		"Fonts found by completed scans.")
+	conversionsTotal = metrics.NewCounter("gofindmyfonts_conversions_total",
+		"Conversions by type, such as ttf_to_woff2, and outcome: success, failure, timeout, skipped or cancelled.",
+		"type", "outcome")
+	conversionDuration = metrics.NewHistogram("gofindmyfonts_conversion_duration_seconds",
+		"Time taken by the conversion tool, by type.",
+		metrics.ExponentialBuckets(0.01, 2, 14), "type")
+	conversionCache = metrics.NewCounter("gofindmyfonts_conversion_cache_requests_total",
+		"Requests for a converted font by result: hit when it was cached, miss when it had to be converted.",
+		"result")
+	downloadsTotal = metrics.NewCounter("gofindmyfonts_downloads_total",
+		"Downloads by kind: font or zip.", "kind")
+	downloadBytes = metrics.NewCounter("gofindmyfonts_download_bytes_total",
+		"Bytes sent in downloads by kind: font or zip.", "kind")
+	zipSize = metrics.NewHistogram("gofindmyfonts_zip_size_bytes",
+		"Size of the zip files sent by download-all.",
+		metrics.ExponentialBuckets(64*1024, 4, 10))
+	cleanupRemovedFiles = metrics.NewCounter("gofindmyfonts_cleanup_removed_files_total",
+		"Expired conversions removed from the cache by cleanup.")
+	cleanupRemovedBytes = metrics.NewCounter("gofindmyfonts_cleanup_removed_bytes_total",
+		"Bytes of expired conversions removed from the cache by cleanup.")
+)
+
+// conversionType names a conversion for metric labels, e.g. "ttf_to_woff2"
+func conversionType(sourceFormat, format string) string {
+	return strings.TrimPrefix(sourceFormat, ".") + "_to_" + strings.TrimPrefix(format, ".")
+}
+
+// handleMetrics serves the server metrics in the Prometheus text exposition format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	if err := metrics.WriteText(w); err != nil {
+		logging.WarnContext(r.Context(), "Failed to write metrics", "handle_metrics", "", err)
+	}
+}
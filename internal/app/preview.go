@@ -3,9 +3,10 @@ package app
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,13 +14,14 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/bradsec/gofindmyfonts/internal/logging"
+	"github.com/bradsec/gofindmyfonts/internal/sfnt"
 )
 
-const (
-	progressBufferSize = 10 // Smaller buffer size for better backpressure handling
-)
+// conversionWaitDelay bounds how long a killed conversion tool may hold its output pipes open
+const conversionWaitDelay = 2 * time.Second
 
 // FontProcessError represents a custom error type for font processing operations
 type FontProcessError struct {
@@ -37,24 +39,43 @@ func (e *FontProcessError) Error() string {
 
 // FontPreview represents a font and its preview information
 type FontPreview struct {
-	Name    string            `json:"name"`
-	Preview string            `json:"preview"`
-	Formats map[string]string `json:"formats"`
+	ID       string                  `json:"id"`
+	Name     string                  `json:"name"`
+	Preview  string                  `json:"preview"`
+	Formats  map[string]string       `json:"formats"`
+	Status   map[string]FormatStatus `json:"status"`
+	Metrics  *sfnt.Metrics           `json:"metrics,omitempty"`
+	Report   *sfnt.MetricsReport     `json:"metricsReport,omitempty"`
+	Features *sfnt.LayoutFeatures    `json:"features,omitempty"`
 }
 
 // FontVariant represents a font with its different format variations
 type FontVariant struct {
+	ID          string // Stable identity derived from file contents
 	Name        string
 	Location    map[string]string // Map of extension -> path
-	PreviewPath string            // Path to WOFF2/WOFF preview file
+	Sources     map[string]string // Map of extension -> file system path of the original file
+	Converted   map[string]string // Map of extension -> file system path of the converted file
+	Status      map[string]FormatStatus
+	PreviewPath string // Path to WOFF2/WOFF preview file
+	Metrics     *sfnt.Metrics
+	Features    *sfnt.LayoutFeatures
 }
 
-// ConversionProgress represents the progress of font conversion
+// identityFormats lists the formats used to derive a font's identity, in order of preference
+var identityFormats = []string{".ttf", ".otf", ".woff2", ".woff"}
+
+// ConversionProgress represents the progress of font conversion. It is the
+// payload of conversion_progress events; ElapsedMs is the time since the batch
+// started, so clients can estimate the time remaining.
 type ConversionProgress struct {
+	Job         string `json:"job"`
+	Format      string `json:"format"`
 	Total       int    `json:"total"`
 	Current     int    `json:"current"`
 	CurrentFont string `json:"currentFont"`
 	Stage       string `json:"stage"`
+	ElapsedMs   int64  `json:"elapsedMs"`
 }
 
 // ConversionJob represents a single font conversion job
@@ -62,6 +83,7 @@ type ConversionJob struct {
 	variant      *FontVariant
 	sourceFile   string
 	sourceFormat string
+	format       string // Target format, e.g. ".woff2"
 	outputPath   string
 }
 
@@ -72,7 +94,13 @@ type PreviewGenerator struct {
 	config       *Config
 	previewCache sync.Map
 	workerPool   chan struct{}
-	progress     chan string
+	events       *progressHub
+	jobs         *jobRegistry
+	quarantine   *Quarantine
+	conversions  *flightGroup
+	registry     *fontRegistry // Fonts of recent scans, for download-all
+	readDir      dirReader     // Lists directories during scans; os.ReadDir when nil
+	files        *fontFiles    // Opens font files and archive members
 }
 
 // NewPreviewGenerator creates a new PreviewGenerator instance
@@ -84,19 +112,20 @@ func NewPreviewGenerator(config *Config) *PreviewGenerator {
 		config:       config,
 		workerPool:   make(chan struct{}, config.MaxConcurrent),
 		previewCache: sync.Map{},
-		progress:     make(chan string, progressBufferSize),
+		events:       newProgressHub(),
+		jobs:         newJobRegistry(),
+		quarantine:   NewQuarantine(filepath.Join(config.DataDir, "quarantine.json"), config.QuarantineAfter),
+		conversions:  newFlightGroup(),
+		registry:     newFontRegistry(),
+		files:        newFontFiles(config.MaxFileSize),
 	}
 }
 
-// GetProgressChan returns the progress channel
-func (pg *PreviewGenerator) GetProgressChan() chan string {
-	return pg.progress
-}
-
 // Close cleans up resources used by the generator
 func (pg *PreviewGenerator) Close() {
 	pg.cancel() // Cancel any ongoing operations
-	close(pg.progress)
+	pg.events.close()
+	pg.files.close()
 	// Clear the cache
 	pg.previewCache.Range(func(key, value interface{}) bool {
 		pg.previewCache.Delete(key)
@@ -104,17 +133,9 @@ func (pg *PreviewGenerator) Close() {
 	})
 }
 
-// sendProgress sends a progress update message in a non-blocking way
-func (pg *PreviewGenerator) sendProgress(msg string) {
-	select {
-	case pg.progress <- msg:
-		logging.Info("Progress update sent", "progress", msg)
-	case <-pg.ctx.Done():
-		// Context cancelled, stop sending progress
-		return
-	default:
-		logging.Info("Progress update dropped", "progress", msg)
-	}
+// publish sends a progress event for the job ctx belongs to
+func (pg *PreviewGenerator) publish(ctx context.Context, eventType string, data interface{}) {
+	pg.events.publish(jobIDFromContext(ctx), eventType, data)
 }
 
 func ensureConvertedDir(config *Config) error {
@@ -123,26 +144,15 @@ func ensureConvertedDir(config *Config) error {
 		logging.Error("Failed to create converted directory", "ensure_dir", convertedDir, err)
 		return fmt.Errorf("failed to create converted directory: %w", err)
 	}
-	logging.Info("Ensured converted directory exists", "ensure_dir", convertedDir)
+	logging.Debug("Ensured converted directory exists", "ensure_dir", convertedDir)
 	return nil
 }
 
-func decodeFilePath(encodedPath string) (string, error) {
-	path := strings.TrimPrefix(encodedPath, "/download?path=")
-	decodedPath, err := url.QueryUnescape(path)
-	if err != nil {
-		logging.Error("Failed to decode file path", "decode_path", encodedPath, err)
-		return "", fmt.Errorf("failed to decode file path: %w", err)
-	}
-	logging.Info("Successfully decoded file path", "decode_path", decodedPath)
-	return decodedPath, nil
-}
+// copyFile safely copies a font file or archive member from src to dst
+func copyFile(files *fontFiles, src, dst string) error {
+	logging.Debug("Copying file", "copy_file", fmt.Sprintf("src: %s, dst: %s", src, dst))
 
-// copyFile safely copies a file from src to dst
-func copyFile(src, dst string) error {
-	logging.Info("Copying file", "copy_file", fmt.Sprintf("src: %s, dst: %s", src, dst))
-
-	sourceFile, err := os.Open(src)
+	sourceFile, _, err := files.open(src)
 	if err != nil {
 		logging.Error("Failed to open source file", "copy_file", src, err)
 		return fmt.Errorf("failed to open source file: %w", err)
@@ -167,40 +177,47 @@ func copyFile(src, dst string) error {
 		return fmt.Errorf("failed to copy file: %w", err)
 	}
 
-	logging.Info("Successfully copied file", "copy_file", dst)
+	logging.Debug("Successfully copied file", "copy_file", dst)
 	return destFile.Sync()
 }
 
-// convertToWoff2 converts a TTF/OTF file to WOFF2 format
-func convertToWoff2(ttfPath string, outputPath string) (string, error) {
-	logging.Info("Starting WOFF2 conversion", "convert_woff2", fmt.Sprintf("from: %s to: %s", ttfPath, outputPath))
+// convertToWoff2 converts a TTF/OTF file to WOFF2 format. Cancelling ctx kills
+// woff2_compress and removes any partial output.
+func convertToWoff2(ctx context.Context, files *fontFiles, ttfPath string, outputPath string) (string, error) {
+	logging.DebugContext(ctx, "Starting WOFF2 conversion", "convert_woff2", fmt.Sprintf("from: %s to: %s", ttfPath, outputPath))
 
 	outputDir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		logging.Error("Failed to create output directory", "convert_woff2", outputDir, err)
+		logging.ErrorContext(ctx, "Failed to create output directory", "convert_woff2", outputDir, err)
 		return "", &FontProcessError{Op: "create_dir", Path: outputDir, Err: err}
 	}
 
 	// Check if output already exists and is valid
 	if info, err := os.Stat(outputPath); err == nil && info.Size() > 0 {
-		logging.Info("WOFF2 file already exists", "convert_woff2", outputPath)
+		logging.DebugContext(ctx, "WOFF2 file already exists", "convert_woff2", outputPath)
 		return outputPath, nil
 	}
 
 	// Create temporary file for conversion
 	tmpFile := strings.TrimSuffix(outputPath, ".woff2") + filepath.Ext(ttfPath)
-	if err := copyFile(ttfPath, tmpFile); err != nil {
-		logging.Error("Failed to create temporary file", "convert_woff2", tmpFile, err)
+	if err := copyFile(files, ttfPath, tmpFile); err != nil {
+		logging.ErrorContext(ctx, "Failed to create temporary file", "convert_woff2", tmpFile, err)
 		return "", &FontProcessError{Op: "copy", Path: ttfPath, Err: err}
 	}
 	defer os.Remove(tmpFile)
 
-	logging.Info("Running woff2_compress", "convert_woff2", tmpFile)
-	cmd := exec.Command("woff2_compress", filepath.Base(tmpFile))
+	logging.DebugContext(ctx, "Running woff2_compress", "convert_woff2", tmpFile)
+	cmd := exec.CommandContext(ctx, "woff2_compress", filepath.Base(tmpFile))
 	cmd.Dir = outputDir
+	cmd.WaitDelay = conversionWaitDelay
 
 	if output, err := cmd.CombinedOutput(); err != nil {
-		logging.Error("WOFF2 compression failed", "convert_woff2", tmpFile, fmt.Errorf("%v: %s", err, string(output)))
+		removePartialOutput(ctx, outputPath)
+		if ctx.Err() != nil {
+			logging.InfoContext(ctx, fmt.Sprintf("WOFF2 compression stopped: %v", ctx.Err()), "convert_woff2", tmpFile)
+			return "", &FontProcessError{Op: "woff2_compress", Path: tmpFile, Err: ctx.Err()}
+		}
+		logging.ErrorContext(ctx, "WOFF2 compression failed", "convert_woff2", tmpFile, fmt.Errorf("%v: %s", err, string(output)))
 		return "", &FontProcessError{
 			Op:   "woff2_compress",
 			Path: tmpFile,
@@ -210,7 +227,7 @@ func convertToWoff2(ttfPath string, outputPath string) (string, error) {
 
 	// Verify output file was created and is not empty
 	if info, err := os.Stat(outputPath); err != nil || info.Size() == 0 {
-		logging.Error("WOFF2 output verification failed", "convert_woff2", outputPath, fmt.Errorf("file not created or empty"))
+		logging.ErrorContext(ctx, "WOFF2 output verification failed", "convert_woff2", outputPath, fmt.Errorf("file not created or empty"))
 		return "", &FontProcessError{
 			Op:   "verify",
 			Path: outputPath,
@@ -218,29 +235,30 @@ func convertToWoff2(ttfPath string, outputPath string) (string, error) {
 		}
 	}
 
-	logging.Info("Successfully converted to WOFF2", "convert_woff2", outputPath)
+	logging.InfoContext(ctx, "Successfully converted to WOFF2", "convert_woff2", outputPath)
 	return outputPath, nil
 }
 
-// convertToTTF converts a WOFF2 file to TTF format
-func convertToTTF(woff2Path string, outputPath string) error {
-	logging.Info("Starting TTF conversion", "convert_ttf", fmt.Sprintf("from: %s to: %s", woff2Path, outputPath))
+// convertToTTF converts a WOFF2 file to TTF format. Cancelling ctx kills
+// woff2_decompress and removes any partial output.
+func convertToTTF(ctx context.Context, files *fontFiles, woff2Path string, outputPath string) error {
+	logging.DebugContext(ctx, "Starting TTF conversion", "convert_ttf", fmt.Sprintf("from: %s to: %s", woff2Path, outputPath))
 
 	outputDir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		logging.Error("Failed to create output directory", "convert_ttf", outputDir, err)
+		logging.ErrorContext(ctx, "Failed to create output directory", "convert_ttf", outputDir, err)
 		return &FontProcessError{Op: "create_dir", Path: outputDir, Err: err}
 	}
 
 	// Check if output already exists and is valid
 	if info, err := os.Stat(outputPath); err == nil && info.Size() > 0 {
-		logging.Info("TTF file already exists", "convert_ttf", outputPath)
+		logging.DebugContext(ctx, "TTF file already exists", "convert_ttf", outputPath)
 		return nil
 	}
 
 	// Verify source file exists
-	if _, err := os.Stat(woff2Path); err != nil {
-		logging.Error("Source file not found", "convert_ttf", woff2Path, err)
+	if _, err := files.stat(woff2Path); err != nil {
+		logging.ErrorContext(ctx, "Source file not found", "convert_ttf", woff2Path, err)
 		return &FontProcessError{
 			Op:   "check_source",
 			Path: woff2Path,
@@ -248,24 +266,30 @@ func convertToTTF(woff2Path string, outputPath string) error {
 		}
 	}
 
-	// Create temporary file for conversion
-	tmpFile := filepath.Join(outputDir, filepath.Base(woff2Path))
-	if err := copyFile(woff2Path, tmpFile); err != nil {
-		logging.Error("Failed to create temporary file", "convert_ttf", tmpFile, err)
+	// Create temporary file for conversion, named so woff2_decompress writes outputPath
+	tmpFile := strings.TrimSuffix(outputPath, ".ttf") + ".woff2"
+	if err := copyFile(files, woff2Path, tmpFile); err != nil {
+		logging.ErrorContext(ctx, "Failed to create temporary file", "convert_ttf", tmpFile, err)
 		return &FontProcessError{Op: "copy", Path: woff2Path, Err: err}
 	}
 	defer func() {
 		if err := os.Remove(tmpFile); err != nil {
-			logging.Error("Failed to remove temporary file", "convert_ttf", tmpFile, err)
+			logging.ErrorContext(ctx, "Failed to remove temporary file", "convert_ttf", tmpFile, err)
 		}
 	}()
 
-	logging.Info("Running woff2_decompress", "convert_ttf", tmpFile)
-	cmd := exec.Command("woff2_decompress", filepath.Base(tmpFile))
+	logging.DebugContext(ctx, "Running woff2_decompress", "convert_ttf", tmpFile)
+	cmd := exec.CommandContext(ctx, "woff2_decompress", filepath.Base(tmpFile))
 	cmd.Dir = outputDir
+	cmd.WaitDelay = conversionWaitDelay
 
 	if output, err := cmd.CombinedOutput(); err != nil {
-		logging.Error("TTF decompression failed", "convert_ttf", tmpFile, fmt.Errorf("%v: %s", err, string(output)))
+		removePartialOutput(ctx, outputPath)
+		if ctx.Err() != nil {
+			logging.InfoContext(ctx, fmt.Sprintf("TTF decompression stopped: %v", ctx.Err()), "convert_ttf", tmpFile)
+			return &FontProcessError{Op: "woff2_decompress", Path: tmpFile, Err: ctx.Err()}
+		}
+		logging.ErrorContext(ctx, "TTF decompression failed", "convert_ttf", tmpFile, fmt.Errorf("%v: %s", err, string(output)))
 		return &FontProcessError{
 			Op:   "woff2_decompress",
 			Path: tmpFile,
@@ -275,7 +299,7 @@ func convertToTTF(woff2Path string, outputPath string) error {
 
 	// Verify output file was created and is not empty
 	if info, err := os.Stat(outputPath); err != nil || info.Size() == 0 {
-		logging.Error("TTF output verification failed", "convert_ttf", outputPath, fmt.Errorf("file not created or empty"))
+		logging.ErrorContext(ctx, "TTF output verification failed", "convert_ttf", outputPath, fmt.Errorf("file not created or empty"))
 		return &FontProcessError{
 			Op:   "verify",
 			Path: outputPath,
@@ -283,99 +307,99 @@ func convertToTTF(woff2Path string, outputPath string) error {
 		}
 	}
 
-	logging.Info("Successfully converted to TTF", "convert_ttf", outputPath)
+	logging.InfoContext(ctx, "Successfully converted to TTF", "convert_ttf", outputPath)
 	return nil
 }
 
-// findFonts finds all font files in a directory and groups them by base name
-func findFonts(root string) (map[string]*FontVariant, error) {
-	logging.Info("Starting font search", "find_fonts", root)
-
-	fonts := make(map[string]*FontVariant)
-	var mu sync.Mutex
-	var walkErr error
-
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			if os.IsPermission(err) {
-				logging.Error("Permission denied", "find_fonts", path, err)
-				return filepath.SkipDir
-			}
-			if walkErr == nil {
-				walkErr = &FontProcessError{
-					Op:   "access",
-					Path: path,
-					Err:  err,
-				}
-			}
-			return nil
-		}
-
-		if !info.IsDir() {
-			ext := strings.ToLower(filepath.Ext(path))
-			if ext == ".ttf" || ext == ".otf" || ext == ".woff" || ext == ".woff2" {
-				baseName := strings.TrimSuffix(filepath.Base(path), ext)
-
-				mu.Lock()
-				if _, exists := fonts[baseName]; !exists {
-					fonts[baseName] = &FontVariant{
-						Name:     baseName,
-						Location: make(map[string]string),
-					}
-				}
+// removePartialOutput deletes a conversion output left behind by a failed or killed tool
+func removePartialOutput(ctx context.Context, outputPath string) {
+	if err := os.Remove(outputPath); err == nil {
+		logging.InfoContext(ctx, "Removed partial output", "remove_partial", outputPath)
+	} else if !os.IsNotExist(err) {
+		logging.ErrorContext(ctx, "Failed to remove partial output", "remove_partial", outputPath, err)
+	}
+}
 
-				downloadURL := "/download?path=" + url.QueryEscape(path)
-				fonts[baseName].Location[ext] = downloadURL
+// previewPriority is the order formats are preferred in for previews
+var previewPriority = []string{".woff2", ".otf", ".ttf", ".woff"}
 
-				if ext == ".woff2" ||
-					(ext == ".woff" && fonts[baseName].PreviewPath == "") ||
-					((ext == ".ttf" || ext == ".otf") && fonts[baseName].PreviewPath == "") {
-					fonts[baseName].PreviewPath = downloadURL
-				}
-				mu.Unlock()
+// parseableFormats lists the formats the sfnt parser can read, in order of preference
+var parseableFormats = []string{".ttf", ".otf", ".woff"}
 
-				logging.Info(fmt.Sprintf("Found font: %s (%s)", baseName, ext), "find_fonts", path)
-			}
+// readFontInfo reads the vertical metrics and layout features of a font from the
+// first parseable original file, falling back to a converted TTF for WOFF2-only fonts
+func readFontInfo(ctx context.Context, files *fontFiles, variant *FontVariant) {
+	var candidates []string
+	for _, ext := range parseableFormats {
+		if path, ok := variant.Sources[ext]; ok {
+			candidates = append(candidates, path)
 		}
-		return nil
-	})
-
-	if walkErr != nil {
-		logging.Error("Error during directory walk", "find_fonts", root, walkErr)
-		return nil, walkErr
+	}
+	if path, ok := variant.Converted[".ttf"]; ok {
+		candidates = append(candidates, path)
 	}
 
-	if err != nil {
-		logging.Error("Error walking directory", "find_fonts", root, err)
-		return nil, &FontProcessError{
-			Op:   "walk",
-			Path: root,
-			Err:  fmt.Errorf("error walking directory: %w", err),
+	for _, path := range candidates {
+		font, err := files.parse(path)
+		if err != nil {
+			logging.WarnContext(ctx, "Failed to parse font", "read_font_info", path, err)
+			continue
+		}
+		metrics, err := font.Metrics()
+		if err != nil {
+			logging.WarnContext(ctx, "Failed to read font metrics", "read_font_info", path, err)
+			continue
 		}
+		features, err := font.Features()
+		if err != nil {
+			logging.WarnContext(ctx, "Failed to read layout features", "read_font_info", path, err)
+		}
+		variant.Metrics = metrics
+		variant.Features = features
+		return
 	}
+}
 
-	if len(fonts) == 0 {
-		logging.Error("No fonts found", "find_fonts", root, fmt.Errorf("no font files found"))
-		return nil, &FontProcessError{
-			Op:   "scan",
-			Path: root,
-			Err:  fmt.Errorf("no font files found in directory"),
+// identitySource returns the source file a font's identity is derived from
+func identitySource(variant *FontVariant) string {
+	for _, ext := range identityFormats {
+		if path, ok := variant.Sources[ext]; ok {
+			return path
 		}
 	}
+	return ""
+}
+
+// fontIdentity returns a stable identifier for a font, derived from the contents
+// of its identity source so that a moved or renamed file keeps its identity.
+// If the file cannot be read, the identifier falls back to a hash of its path.
+func fontIdentity(files *fontFiles, path string) (string, error) {
+	hash := sha256.New()
+	file, _, err := files.open(path)
+	if err != nil {
+		hash.Write([]byte(path))
+		return hex.EncodeToString(hash.Sum(nil))[:16], err
+	}
+	defer file.Close()
 
-	logging.Info(fmt.Sprintf("Found %d fonts", len(fonts)), "find_fonts", root)
-	return fonts, nil
+	if _, err := io.Copy(hash, file); err != nil {
+		hash.Reset()
+		hash.Write([]byte(path))
+		return hex.EncodeToString(hash.Sum(nil))[:16], err
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16], nil
 }
 
 func (pg *PreviewGenerator) processConversions(
+	ctx context.Context,
 	jobs []ConversionJob,
-	progress chan<- ConversionProgress,
-	converter func(string, string) (string, error),
+	onComplete func(*FontVariant),
 ) {
 	totalJobs := len(jobs)
 	var completed int32
+	started := time.Now()
 
-	logging.Info(fmt.Sprintf("Starting conversion batch: %d jobs", totalJobs), "process_conversions", "")
+	logging.InfoContext(ctx, fmt.Sprintf("Starting conversion batch: %d jobs", totalJobs), "process_conversions", "")
 
 	jobsChan := make(chan ConversionJob, totalJobs)
 	for _, job := range jobs {
@@ -392,7 +416,7 @@ func (pg *PreviewGenerator) processConversions(
 		numWorkers = pg.config.MaxConcurrent
 	}
 
-	logging.Info(fmt.Sprintf("Starting %d worker(s)", numWorkers), "process_conversions", "")
+	logging.DebugContext(ctx, fmt.Sprintf("Starting %d worker(s)", numWorkers), "process_conversions", "")
 
 	var wg sync.WaitGroup
 	for i := 0; i < numWorkers; i++ {
@@ -401,207 +425,373 @@ func (pg *PreviewGenerator) processConversions(
 			defer wg.Done()
 			for job := range jobsChan {
 				select {
-				case <-pg.ctx.Done():
-					logging.Info("Conversion cancelled", "process_conversions", job.variant.Name)
+				case <-ctx.Done():
+					logging.InfoContext(ctx, "Conversion cancelled", "process_conversions", job.variant.Name)
 					return // Context cancelled, stop processing
 				default:
 				}
 
-				conversionType := "WOFF2"
-				if strings.HasSuffix(job.outputPath, ".ttf") {
-					conversionType = "TTF"
-				}
+				ext := job.format
+				conversionType := strings.ToUpper(strings.TrimPrefix(ext, "."))
 
-				logging.Info(fmt.Sprintf("Processing %s conversion", conversionType), "process_conversions", job.variant.Name)
+				logging.DebugContext(ctx, fmt.Sprintf("Processing %s conversion", conversionType), "process_conversions", job.variant.Name)
 
-				convertedPath, err := converter(job.sourceFile, job.outputPath)
+				convertedPath, cached, err := pg.Convert(ctx, job.sourceFile, ext)
 				if err == nil {
-					ext := filepath.Ext(job.outputPath)
-					downloadURL := fmt.Sprintf("/download?path=%s&filename=%s%s",
-						url.QueryEscape(convertedPath),
-						url.QueryEscape(job.variant.Name),
-						ext)
-
-					job.variant.Location[ext] = downloadURL
-					if ext == ".woff2" && job.variant.PreviewPath == "" {
-						job.variant.PreviewPath = downloadURL
+					job.variant.Converted[ext] = convertedPath
+					if cached {
+						job.variant.Status[ext] = FormatStatus{State: FormatCached}
+					} else {
+						job.variant.Status[ext] = FormatStatus{State: FormatConverted}
 					}
-					logging.Info(fmt.Sprintf("Successfully created %s version", conversionType), "process_conversions", job.variant.Name)
-				} else {
-					logging.Error(fmt.Sprintf("Error converting to %s", conversionType), "process_conversions", job.variant.Name, err)
+					logging.DebugContext(ctx, fmt.Sprintf("Successfully created %s version", conversionType), "process_conversions", job.variant.Name)
+				} else if ctx.Err() == nil {
+					delete(job.variant.Location, ext)
+					job.variant.Status[ext] = FormatStatus{State: FormatFailed, Reason: err.Error()}
+					logging.ErrorContext(ctx, fmt.Sprintf("Error converting to %s", conversionType), "process_conversions", job.variant.Name, err)
+					pg.publish(ctx, EventConversionFailed, ConversionFailed{
+						Job:    jobIDFromContext(ctx),
+						Font:   job.variant.Name,
+						Format: ext,
+						Error:  err.Error(),
+					})
+				}
+
+				if ctx.Err() == nil {
+					onComplete(job.variant)
 				}
 
 				current := atomic.AddInt32(&completed, 1)
-				select {
-				case progress <- ConversionProgress{
+				if ctx.Err() != nil {
+					return
+				}
+				pg.publish(ctx, EventConversionProgress, ConversionProgress{
+					Job:         jobIDFromContext(ctx),
+					Format:      ext,
 					Total:       totalJobs,
 					Current:     int(current),
 					CurrentFont: job.variant.Name,
 					Stage:       fmt.Sprintf("Converting to %s", conversionType),
-				}:
-					logging.Info(fmt.Sprintf("Progress update: %d/%d", current, totalJobs), "process_conversions", job.variant.Name)
-				case <-pg.ctx.Done():
-					return
-				default:
-					logging.Info(fmt.Sprintf("Progress update skipped: %d/%d", current, totalJobs), "process_conversions", job.variant.Name)
-				}
+					ElapsedMs:   millisSince(started),
+				})
 			}
 		}()
 	}
 
 	wg.Wait()
-	logging.Info("Conversion batch completed", "process_conversions", "")
+	logging.InfoContext(ctx, "Conversion batch completed", "process_conversions", "")
 }
 
-// ProcessFonts processes all fonts in the given directory
-func (pg *PreviewGenerator) ProcessFonts(fontDir string) ([]FontPreview, error) {
-	logging.Info("Starting font processing", "process_fonts", fontDir)
+// runConversion runs a single conversion job under the per-conversion timeout,
+// skipping files that exceed MaxFileSize or are quarantined after repeated failures.
+// It reports whether the output was already cached by an earlier scan.
+func (pg *PreviewGenerator) runConversion(
+	ctx context.Context,
+	job ConversionJob,
+	converter func(context.Context, *fontFiles, string, string) (string, error),
+) (string, bool, error) {
+	if info, err := os.Stat(job.outputPath); err == nil && info.Size() > 0 {
+		logging.DebugContext(ctx, "Using cached conversion", "run_conversion", job.outputPath)
+		return job.outputPath, true, nil
+	}
+
+	info, err := pg.files.stat(job.sourceFile)
+	if err != nil {
+		return "", false, &FontProcessError{Op: "stat", Path: job.sourceFile, Err: err}
+	}
+
+	kind := conversionType(job.sourceFormat, job.format)
+	if info.Size() > pg.config.MaxFileSize {
+		conversionsTotal.Inc(kind, "skipped")
+		return "", false, &FontProcessError{
+			Op:   "size_limit",
+			Path: job.sourceFile,
+			Err:  fmt.Errorf("file size %d exceeds limit of %d bytes", info.Size(), pg.config.MaxFileSize),
+		}
+	}
+
+	if entry, quarantined := pg.quarantine.Check(job.sourceFile, info); quarantined {
+		conversionsTotal.Inc(kind, "skipped")
+		return "", false, &FontProcessError{
+			Op:   "quarantine",
+			Path: job.sourceFile,
+			Err:  fmt.Errorf("skipped after %d failed conversions, last error: %s", entry.Failures, entry.LastError),
+		}
+	}
+
+	convCtx, cancel := context.WithTimeout(ctx, pg.config.ConversionTimeout)
+	defer cancel()
+
+	started := time.Now()
+	convertedPath, err := converter(convCtx, pg.files, job.sourceFile, job.outputPath)
+	conversionDuration.Observe(time.Since(started).Seconds(), kind)
+	if err != nil {
+		// A cancelled scan is not the font's fault
+		if ctx.Err() != nil {
+			conversionsTotal.Inc(kind, "cancelled")
+			return "", false, err
+		}
+		outcome := "failure"
+		if convCtx.Err() == context.DeadlineExceeded {
+			outcome = "timeout"
+			err = &FontProcessError{
+				Op:   "timeout",
+				Path: job.sourceFile,
+				Err:  fmt.Errorf("conversion exceeded %s", pg.config.ConversionTimeout),
+			}
+		}
+		conversionsTotal.Inc(kind, outcome)
+		pg.quarantine.RecordFailure(job.sourceFile, info, err)
+		return "", false, err
+	}
+
+	conversionsTotal.Inc(kind, "success")
+	pg.quarantine.RecordSuccess(job.sourceFile)
+	return convertedPath, false, nil
+}
+
+// offerConversions adds the formats a font can be converted to but does not have
+// on disk. Each gets a download link that converts on first request, and is
+// marked cached if an earlier conversion is still valid, or failed if the
+// source is quarantined. It returns the conversions that are still to be done.
+func (pg *PreviewGenerator) offerConversions(variant *FontVariant) []ConversionJob {
+	var jobs []ConversionJob
+	for _, format := range []string{".woff2", ".ttf"} {
+		if _, exists := variant.Sources[format]; exists {
+			continue
+		}
+		for _, sourceFormat := range conversionSources[format] {
+			source, ok := variant.Sources[sourceFormat]
+			if !ok {
+				continue
+			}
+			job := ConversionJob{
+				variant:      variant,
+				sourceFile:   source,
+				sourceFormat: sourceFormat,
+				format:       format,
+			}
+
+			if path, cached := pg.cachedConversion(source, format); cached {
+				variant.Location[format] = conversionURL(source, format, variant.Name)
+				variant.Converted[format] = path
+				variant.Status[format] = FormatStatus{State: FormatCached}
+			} else if info, err := pg.files.stat(source); err == nil {
+				if entry, quarantined := pg.quarantine.Check(source, info); quarantined {
+					variant.Status[format] = FormatStatus{State: FormatFailed, Reason: entry.LastError}
+				} else {
+					variant.Location[format] = conversionURL(source, format, variant.Name)
+					variant.Status[format] = FormatStatus{State: FormatOnDemand}
+					jobs = append(jobs, job)
+				}
+			}
+			break
+		}
+	}
+	return jobs
+}
+
+// FilterByFeatures returns the fonts that support every one of the given OpenType feature tags
+func FilterByFeatures(previews []FontPreview, features []string) []FontPreview {
+	if len(features) == 0 {
+		return previews
+	}
+
+	filtered := []FontPreview{}
+	for _, preview := range previews {
+		if supportsFeatures(preview, features) {
+			filtered = append(filtered, preview)
+		}
+	}
+	return filtered
+}
+
+// supportsFeatures reports whether a font supports every one of the given feature tags
+func supportsFeatures(preview FontPreview, features []string) bool {
+	if len(features) == 0 {
+		return true
+	}
+	if preview.Features == nil {
+		return false
+	}
+	for _, feature := range features {
+		if !preview.Features.HasFeature(feature) {
+			return false
+		}
+	}
+	return true
+}
+
+// preview builds the client view of a variant. The format maps are copied so
+// the result can be encoded while conversions keep updating the variant.
+func (v *FontVariant) preview() FontPreview {
+	preview := FontPreview{
+		ID:       v.ID,
+		Name:     v.Name,
+		Preview:  v.PreviewPath,
+		Formats:  make(map[string]string, len(v.Location)),
+		Status:   make(map[string]FormatStatus, len(v.Status)),
+		Metrics:  v.Metrics,
+		Features: v.Features,
+	}
+	for ext, location := range v.Location {
+		preview.Formats[ext] = location
+	}
+	for ext, status := range v.Status {
+		preview.Status[ext] = status
+	}
+	if v.Metrics != nil {
+		preview.Report = v.Metrics.Report(v.Name)
+	}
+	return preview
+}
+
+// ProcessFonts processes all fonts in the given directory. The scan stops and
+// running conversions are killed when ctx is cancelled. If observer is not nil
+// it receives each font as soon as the walk finds it, while the rest of the
+// tree is still being walked, and again whenever a later file or one of its
+// conversions changes it. Progress is published as scan_started, font_found,
+// conversion_progress, conversion_failed and scan_complete events.
+func (pg *PreviewGenerator) ProcessFonts(ctx context.Context, fontDir string, policy ScanPolicy, observer ScanObserver) (*ScanResult, error) {
+	job := jobIDFromContext(ctx)
+	pg.publish(ctx, EventScanStarted, ScanStarted{Job: job, FontDir: fontDir})
+
+	started := time.Now()
+	result, err := pg.processFonts(ctx, fontDir, policy, observer)
+
+	complete := ScanComplete{Job: job}
+	switch {
+	case err == nil:
+		complete.Summary = &result.Summary
+		scansTotal.Inc("completed")
+		scanDuration.Observe(time.Since(started).Seconds())
+		fontsFound.Add(float64(len(result.Fonts)))
+	case ctx.Err() != nil:
+		complete.Error = err.Error()
+		scansTotal.Inc("cancelled")
+	default:
+		complete.Error = err.Error()
+		scansTotal.Inc("failed")
+	}
+	pg.publish(ctx, EventScanComplete, complete)
+	return result, err
+}
+
+func (pg *PreviewGenerator) processFonts(ctx context.Context, fontDir string, policy ScanPolicy, observer ScanObserver) (*ScanResult, error) {
+	started := time.Now()
+	logging.InfoContext(ctx, "Starting font processing", "process_fonts", fontDir)
 
 	// Validate directory exists and is accessible
 	if info, err := os.Stat(fontDir); err != nil {
 		if os.IsNotExist(err) {
-			logging.Error("Directory does not exist", "process_fonts", fontDir, err)
+			logging.ErrorContext(ctx, "Directory does not exist", "process_fonts", fontDir, err)
 			return nil, &FontProcessError{Op: "validate", Path: fontDir, Err: fmt.Errorf("directory does not exist")}
 		}
-		logging.Error("Error accessing directory", "process_fonts", fontDir, err)
+		logging.ErrorContext(ctx, "Error accessing directory", "process_fonts", fontDir, err)
 		return nil, &FontProcessError{Op: "validate", Path: fontDir, Err: err}
 	} else if !info.IsDir() {
-		logging.Error("Path is not a directory", "process_fonts", fontDir, fmt.Errorf("not a directory"))
+		logging.ErrorContext(ctx, "Path is not a directory", "process_fonts", fontDir, fmt.Errorf("not a directory"))
 		return nil, &FontProcessError{Op: "validate", Path: fontDir, Err: fmt.Errorf("path is not a directory")}
 	}
 
 	// Ensure directories exist
 	if err := ensureConvertedDir(pg.config); err != nil {
-		logging.Error("Failed to create directories", "process_fonts", fontDir, err)
+		logging.ErrorContext(ctx, "Failed to create directories", "process_fonts", fontDir, err)
 		return nil, &FontProcessError{Op: "create_dirs", Err: err}
 	}
 
-	pg.sendProgress("Starting font processing...")
-	pg.sendProgress("Scanning font directory...")
-
-	// Find fonts
-	fontVariants, err := findFonts(fontDir)
+	// Fonts are sent as soon as they are described, while the walk goes on
+	var mu sync.Mutex
+	found := 0
+	fontVariants, offered, truncated, err := pg.discoverFonts(ctx, fontDir, policy, func(variant *FontVariant, first bool, total int) {
+		if !first {
+			if observer != nil {
+				observer.FontUpdated(variant.preview())
+			}
+			return
+		}
+		if observer != nil {
+			observer.FontFound(variant.preview())
+		}
+		mu.Lock()
+		found++
+		event := FontFound{Job: jobIDFromContext(ctx), ID: variant.ID, Name: variant.Name, Found: found, Total: max(total, found)}
+		mu.Unlock()
+		pg.publish(ctx, EventFontFound, event)
+	})
 	if err != nil {
-		logging.Error("Error finding fonts", "process_fonts", fontDir, err)
+		if ctx.Err() != nil {
+			logging.InfoContext(ctx, "Processing cancelled", "process_fonts", fontDir)
+			return nil, &FontProcessError{Op: "process", Err: fmt.Errorf("operation cancelled")}
+		}
+		logging.ErrorContext(ctx, "Error finding fonts", "process_fonts", fontDir, err)
 		return nil, &FontProcessError{Op: "scan", Path: fontDir, Err: err}
 	}
+	timings := ScanTimings{ScanMs: millisSince(started)}
+	conversionStarted := time.Now()
 
-	pg.sendProgress(fmt.Sprintf("Found %d fonts. Preparing for conversion...", len(fontVariants)))
-
-	// Prepare conversion jobs
+	// Missing formats were offered as on-demand conversions. They are only
+	// converted now if ConvertOnScan asks for the cache to be warmed.
 	var woff2Jobs []ConversionJob
 	var ttfJobs []ConversionJob
-
-	for _, variant := range fontVariants {
-		select {
-		case <-pg.ctx.Done():
-			logging.Info("Processing cancelled", "process_fonts", fontDir)
-			return nil, &FontProcessError{Op: "process", Err: fmt.Errorf("operation cancelled")}
-		default:
-		}
-
-		if _, hasWoff2 := variant.Location[".woff2"]; !hasWoff2 {
-			if ttfPath, hasTTF := variant.Location[".ttf"]; hasTTF {
-				if decodedPath, err := decodeFilePath(ttfPath); err == nil {
-					woff2Jobs = append(woff2Jobs, ConversionJob{
-						variant:      variant,
-						sourceFile:   decodedPath,
-						sourceFormat: ".ttf",
-						outputPath:   filepath.Join(pg.config.StaticDir, "converted", variant.Name+".woff2"),
-					})
-				}
-			} else if otfPath, hasOTF := variant.Location[".otf"]; hasOTF {
-				if decodedPath, err := decodeFilePath(otfPath); err == nil {
-					woff2Jobs = append(woff2Jobs, ConversionJob{
-						variant:      variant,
-						sourceFile:   decodedPath,
-						sourceFormat: ".otf",
-						outputPath:   filepath.Join(pg.config.StaticDir, "converted", variant.Name+".woff2"),
-					})
-				}
-			}
-		}
-
-		if woff2Path, hasWoff2 := variant.Location[".woff2"]; hasWoff2 {
-			if _, hasTTF := variant.Location[".ttf"]; !hasTTF {
-				if decodedPath, err := decodeFilePath(woff2Path); err == nil {
-					ttfJobs = append(ttfJobs, ConversionJob{
-						variant:      variant,
-						sourceFile:   decodedPath,
-						sourceFormat: ".woff2",
-						outputPath:   filepath.Join(pg.config.StaticDir, "converted", variant.Name+".ttf"),
-					})
-				}
+	if pg.config.ConvertOnScan {
+		for _, job := range offered {
+			if job.format == ".woff2" {
+				woff2Jobs = append(woff2Jobs, job)
+			} else {
+				ttfJobs = append(ttfJobs, job)
 			}
 		}
 	}
 
-	progressChan := make(chan ConversionProgress, progressBufferSize)
-	done := make(chan struct{})
-
-	// Progress forwarder
-	go func() {
-		defer close(done)
-		for {
-			select {
-			case progress, ok := <-progressChan:
-				if !ok {
-					return
-				}
-				var message string
-				if progress.Stage == "Converting to WOFF2" {
-					message = fmt.Sprintf("WOFF2 conversion: %d/%d - Processing: %s",
-						progress.Current, progress.Total, progress.CurrentFont)
-				} else if progress.Stage == "Converting to TTF" {
-					message = fmt.Sprintf("TTF conversion: %d/%d - Processing: %s",
-						progress.Current, progress.Total, progress.CurrentFont)
-				} else {
-					message = fmt.Sprintf("%s: %d/%d - Current: %s",
-						progress.Stage, progress.Current, progress.Total, progress.CurrentFont)
-				}
-				pg.sendProgress(message)
-			case <-pg.ctx.Done():
-				return
-			}
+	// Fonts without a parseable original get their metrics from the converted TTF
+	converted := func(variant *FontVariant) {
+		if variant.Metrics == nil {
+			readFontInfo(ctx, pg.files, variant)
 		}
-	}()
+		if observer != nil {
+			observer.FontUpdated(variant.preview())
+		}
+	}
 
 	// Process WOFF2 conversions
 	if len(woff2Jobs) > 0 {
-		logging.Info(fmt.Sprintf("Starting WOFF2 conversions (%d files)", len(woff2Jobs)), "process_fonts", fontDir)
-		pg.sendProgress(fmt.Sprintf("Starting WOFF2 conversions (%d files)...", len(woff2Jobs)))
-		pg.processConversions(woff2Jobs, progressChan, convertToWoff2)
+		logging.InfoContext(ctx, fmt.Sprintf("Starting WOFF2 conversions (%d files)", len(woff2Jobs)), "process_fonts", fontDir)
+		pg.processConversions(ctx, woff2Jobs, converted)
 	}
 
 	// Process TTF conversions
 	if len(ttfJobs) > 0 {
-		logging.Info(fmt.Sprintf("Starting TTF conversions (%d files)", len(ttfJobs)), "process_fonts", fontDir)
-		pg.sendProgress(fmt.Sprintf("Starting TTF conversions (%d files)...", len(ttfJobs)))
-		pg.processConversions(ttfJobs, progressChan, func(src, dst string) (string, error) {
-			err := convertToTTF(src, dst)
-			if err != nil {
-				return "", err
-			}
-			return dst, nil
-		})
+		logging.InfoContext(ctx, fmt.Sprintf("Starting TTF conversions (%d files)", len(ttfJobs)), "process_fonts", fontDir)
+		pg.processConversions(ctx, ttfJobs, converted)
+	}
+
+	if ctx.Err() != nil {
+		logging.InfoContext(ctx, "Processing cancelled", "process_fonts", fontDir)
+		return nil, &FontProcessError{Op: "process", Err: fmt.Errorf("operation cancelled")}
 	}
 
-	close(progressChan)
-	<-done
+	timings.ConversionMs = millisSince(conversionStarted)
 
-	// Final completion message
-	pg.sendProgress("All conversions complete! Preparing results...")
-	logging.Info("All conversions complete", "process_fonts", fontDir)
+	// Download-all requests name fonts from this scan by ID
+	pg.registry.record(jobIDFromContext(ctx), fontVariants)
 
-	var results []FontPreview
+	if len(woff2Jobs)+len(ttfJobs) > 0 {
+		logging.InfoContext(ctx, "All conversions complete", "process_fonts", fontDir)
+	}
+
+	results := []FontPreview{}
 	for _, variant := range fontVariants {
-		preview := FontPreview{
-			Name:    variant.Name,
-			Preview: variant.PreviewPath,
-			Formats: variant.Location,
-		}
-		results = append(results, preview)
+		results = append(results, variant.preview())
 	}
 
-	return results, nil
+	sortPreviews(results)
+
+	timings.TotalMs = millisSince(started)
+	summary := newScanSummary(results, timings)
+	summary.Truncated = truncated
+	return &ScanResult{
+		Fonts:   results,
+		Summary: summary,
+	}, nil
 }
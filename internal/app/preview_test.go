@@ -0,0 +1,61 @@
+// internal/app/preview_test.go
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+// Records logged while converting and reading fonts for a scan carry its job ID
+func TestConversionLogsCarryJobID(t *testing.T) {
+	installFakeTools(t)
+	pg := newTestGenerator(t)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	job, ctx, err := pg.StartJob(logging.NewContext(context.Background(), logger), "logjob", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pg.FinishJob(job)
+
+	// The stand-in font converts with the fake tools but cannot be parsed
+	source := filepath.Join(t.TempDir(), "Font.ttf")
+	writeFont(t, source)
+	variant := &FontVariant{
+		Name:      "Font",
+		Location:  map[string]string{},
+		Sources:   map[string]string{".ttf": source},
+		Converted: map[string]string{},
+		Status:    map[string]FormatStatus{},
+	}
+	jobs := []ConversionJob{{variant: variant, sourceFile: source, sourceFormat: ".ttf", format: ".woff2"}}
+	pg.processConversions(ctx, jobs, func(*FontVariant) {})
+	readFontInfo(ctx, pg.files, variant)
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("%v: %s", err, scanner.Text())
+		}
+		op, _ := record["operation"].(string)
+		seen[op] = true
+		if record["job_id"] != "logjob" {
+			t.Errorf("record without job ID: %s", scanner.Text())
+		}
+	}
+	for _, op := range []string{"process_conversions", "convert_woff2", "read_font_info"} {
+		if !seen[op] {
+			t.Errorf("no %s records logged", op)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// internal/app/quarantine.go
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+// QuarantineEntry records conversion failures for a single font file. Entries are
+// tied to the file's size and modification time, so an updated file is retried.
+type QuarantineEntry struct {
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"modTime"`
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"lastError"`
+	LastFailure time.Time `json:"lastFailure"`
+	Quarantined bool      `json:"quarantined"`
+}
+
+// Quarantine tracks fonts that repeatedly fail to convert so they are not
+// retried on every scan. It is persisted to a JSON file.
+type Quarantine struct {
+	mu        sync.Mutex
+	path      string
+	threshold int
+	entries   map[string]*QuarantineEntry
+}
+
+// NewQuarantine loads the quarantine list stored at path. A file is quarantined
+// after threshold consecutive failures.
+func NewQuarantine(path string, threshold int) *Quarantine {
+	q := &Quarantine{
+		path:      path,
+		threshold: threshold,
+		entries:   make(map[string]*QuarantineEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Error("Failed to read quarantine list", "quarantine_load", path, err)
+		}
+		return q
+	}
+
+	var entries []*QuarantineEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		logging.Error("Failed to parse quarantine list", "quarantine_load", path, err)
+		return q
+	}
+	for _, entry := range entries {
+		q.entries[entry.Path] = entry
+	}
+	logging.Info(fmt.Sprintf("Loaded %d quarantine entries", len(q.entries)), "quarantine_load", path)
+	return q
+}
+
+// Check reports the quarantine entry for a file if it is quarantined. Entries
+// for files that have changed since they failed are discarded.
+func (q *Quarantine) Check(path string, info os.FileInfo) (*QuarantineEntry, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, exists := q.entries[path]
+	if !exists {
+		return nil, false
+	}
+	if entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		delete(q.entries, path)
+		q.save()
+		return nil, false
+	}
+	copied := *entry
+	return &copied, entry.Quarantined
+}
+
+// RecordFailure counts a failed conversion and quarantines the file once the threshold is reached
+func (q *Quarantine) RecordFailure(path string, info os.FileInfo, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, exists := q.entries[path]
+	if !exists || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		entry = &QuarantineEntry{
+			Path:    path,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		q.entries[path] = entry
+	}
+
+	entry.Failures++
+	entry.LastError = err.Error()
+	entry.LastFailure = time.Now()
+	if entry.Failures >= q.threshold && !entry.Quarantined {
+		entry.Quarantined = true
+		logging.Error(fmt.Sprintf("Quarantined after %d failures", entry.Failures), "quarantine", path, err)
+	}
+	q.save()
+}
+
+// RecordSuccess clears the failure history of a file
+func (q *Quarantine) RecordSuccess(path string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, exists := q.entries[path]; exists {
+		delete(q.entries, path)
+		q.save()
+	}
+}
+
+// Release removes a file from the quarantine list, or every file if path is empty.
+// It returns the number of entries removed.
+func (q *Quarantine) Release(path string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	removed := 0
+	if path == "" {
+		removed = len(q.entries)
+		q.entries = make(map[string]*QuarantineEntry)
+	} else if _, exists := q.entries[path]; exists {
+		delete(q.entries, path)
+		removed = 1
+	}
+	if removed > 0 {
+		q.save()
+		logging.Info(fmt.Sprintf("Released %d quarantine entries", removed), "quarantine_release", path)
+	}
+	return removed
+}
+
+// Entries returns all failure records sorted by path
+func (q *Quarantine) Entries() []QuarantineEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries := make([]QuarantineEntry, 0, len(q.entries))
+	for _, entry := range q.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// save writes the quarantine list to disk. Callers must hold the lock.
+func (q *Quarantine) save() {
+	entries := make([]*QuarantineEntry, 0, len(q.entries))
+	for _, entry := range q.entries {
+		entries = append(entries, entry)
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		logging.Error("Failed to encode quarantine list", "quarantine_save", q.path, err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		logging.Error("Failed to create quarantine directory", "quarantine_save", q.path, err)
+		return
+	}
+	tmpPath := q.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		logging.Error("Failed to write quarantine list", "quarantine_save", q.path, err)
+		return
+	}
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		os.Remove(tmpPath)
+		logging.Error("Failed to replace quarantine list", "quarantine_save", q.path, err)
+	}
+}
+
+// handleQuarantine lists failure records (GET) and releases files (DELETE ?path=, or all without path)
+func (s *Server) handleQuarantine(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.generator.quarantine.Entries())
+	case http.MethodDelete:
+		removed := s.generator.quarantine.Release(r.URL.Query().Get("path"))
+		writeJSON(w, http.StatusOK, map[string]int{"released": removed})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,95 @@
+// internal/app/registry.go
+package app
+
+import (
+	"sort"
+	"sync"
+)
+
+// registryScans is how many recent scans are kept for download-all requests
+const registryScans = 8
+
+// registeredFont is what the server knows about a font from a scan: where its
+// original files are and what happened to each format
+type registeredFont struct {
+	ID      string
+	Name    string
+	Sources map[string]string
+	Status  map[string]FormatStatus
+}
+
+// registeredScan holds the fonts of one completed scan by ID. IDs come from
+// font contents, so byte-identical fonts at different paths share an ID and
+// are all kept, ordered by name.
+type registeredScan struct {
+	job   string
+	fonts map[string][]registeredFont
+}
+
+// fontRegistry remembers the fonts of recent scans, so download requests can
+// name fonts by ID and the server resolves their files itself
+type fontRegistry struct {
+	mu    sync.Mutex
+	scans []registeredScan // Oldest first
+}
+
+func newFontRegistry() *fontRegistry {
+	return &fontRegistry{}
+}
+
+// record stores the fonts of a completed scan, replacing an earlier scan with
+// the same job ID and dropping the oldest beyond registryScans
+func (r *fontRegistry) record(job string, variants map[string]*FontVariant) {
+	scan := registeredScan{job: job, fonts: make(map[string][]registeredFont, len(variants))}
+	for _, variant := range variants {
+		font := registeredFont{
+			ID:      variant.ID,
+			Name:    variant.Name,
+			Sources: make(map[string]string, len(variant.Sources)),
+			Status:  make(map[string]FormatStatus, len(variant.Status)),
+		}
+		for ext, path := range variant.Sources {
+			font.Sources[ext] = path
+		}
+		for ext, status := range variant.Status {
+			font.Status[ext] = status
+		}
+		scan.fonts[variant.ID] = append(scan.fonts[variant.ID], font)
+	}
+	for _, fonts := range scan.fonts {
+		sort.Slice(fonts, func(i, j int) bool { return fonts[i].Name < fonts[j].Name })
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.scans {
+		if existing.job == job {
+			r.scans = append(r.scans[:i], r.scans[i+1:]...)
+			break
+		}
+	}
+	r.scans = append(r.scans, scan)
+	if len(r.scans) > registryScans {
+		r.scans = r.scans[len(r.scans)-registryScans:]
+	}
+}
+
+// lookup returns the fonts of the scan with the given job ID, or of the most
+// recent scan if job is empty
+func (r *fontRegistry) lookup(job string) (string, map[string][]registeredFont, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.scans) == 0 {
+		return "", nil, false
+	}
+	if job == "" {
+		latest := r.scans[len(r.scans)-1]
+		return latest.job, latest.fonts, true
+	}
+	for _, scan := range r.scans {
+		if scan.job == job {
+			return scan.job, scan.fonts, true
+		}
+	}
+	return "", nil, false
+}
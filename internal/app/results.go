@@ -0,0 +1,88 @@
+// internal/app/results.go
+package app
+
+import (
+	"sort"
+	"time"
+)
+
+// Format states reported for each format of a font
+const (
+	FormatOriginal  = "original"  // Found on disk during the scan
+	FormatOnDemand  = "on-demand" // Converted the first time it is downloaded
+	FormatConverted = "converted" // Converted during this scan
+	FormatCached    = "cached"    // Converted by an earlier scan or download
+	FormatFailed    = "failed"    // Conversion was attempted and failed
+)
+
+// FormatStatus describes how a font format was obtained, or why it is missing
+type FormatStatus struct {
+	State  string `json:"state"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ScanTimings records how long each phase of a scan took, in milliseconds
+type ScanTimings struct {
+	ScanMs       int64 `json:"scanMs"`
+	ConversionMs int64 `json:"conversionMs"`
+	TotalMs      int64 `json:"totalMs"`
+}
+
+// ScanSummary counts the fonts and formats in a scan result
+type ScanSummary struct {
+	Fonts     int         `json:"fonts"`
+	Original  int         `json:"original"`
+	OnDemand  int         `json:"onDemand"`
+	Converted int         `json:"converted"`
+	Cached    int         `json:"cached"`
+	Failed    int         `json:"failed"`
+	Timings   ScanTimings `json:"timings"`
+	Truncated bool        `json:"truncated,omitempty"` // The scan stopped at the maximum number of files
+}
+
+// ScanResult is the response to a scan: the fonts found plus a summary
+type ScanResult struct {
+	Fonts   []FontPreview `json:"fonts"`
+	Summary ScanSummary   `json:"summary"`
+}
+
+// newScanSummary counts format states across fonts
+func newScanSummary(fonts []FontPreview, timings ScanTimings) ScanSummary {
+	summary := ScanSummary{
+		Fonts:   len(fonts),
+		Timings: timings,
+	}
+	for _, font := range fonts {
+		for _, status := range font.Status {
+			switch status.State {
+			case FormatOriginal:
+				summary.Original++
+			case FormatOnDemand:
+				summary.OnDemand++
+			case FormatConverted:
+				summary.Converted++
+			case FormatCached:
+				summary.Cached++
+			case FormatFailed:
+				summary.Failed++
+			}
+		}
+	}
+	return summary
+}
+
+// Refresh recomputes the summary after the font list was filtered, keeping the timings
+func (r *ScanResult) Refresh() {
+	truncated := r.Summary.Truncated
+	r.Summary = newScanSummary(r.Fonts, r.Summary.Timings)
+	r.Summary.Truncated = truncated
+}
+
+// sortPreviews orders fonts by name so results are stable between scans
+func sortPreviews(fonts []FontPreview) {
+	sort.Slice(fonts, func(i, j int) bool { return fonts[i].Name < fonts[j].Name })
+}
+
+func millisSince(start time.Time) int64 {
+	return time.Since(start).Milliseconds()
+}
@@ -0,0 +1,189 @@
+// internal/app/scanpolicy.go
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ScanPolicy controls which directories and files a scan visits
+type ScanPolicy struct {
+	FollowSymlinks bool     // Descend into symlinked directories, each real directory once
+	IncludeHidden  bool     // Visit files and directories whose names start with a dot
+	Archives       bool     // Index the font files inside zip archives
+	Exclude        []string // Gitignore-style patterns, relative to the scanned directory
+	MaxDepth       int      // Directory levels to visit, 1 for only the scanned directory; 0 for no limit
+	MaxFiles       int      // Font files to collect before stopping; 0 for no limit
+	Workers        int      // Directories read at the same time; not settable per request
+}
+
+// ScanPolicy returns the configured defaults for scans
+func (c *Config) ScanPolicy() ScanPolicy {
+	return ScanPolicy{
+		FollowSymlinks: c.ScanFollowSymlinks,
+		IncludeHidden:  c.ScanHidden,
+		Archives:       c.ScanArchives,
+		Exclude:        append([]string(nil), c.ScanExclude...),
+		MaxDepth:       c.ScanMaxDepth,
+		MaxFiles:       c.ScanMaxFiles,
+		Workers:        c.ScanWorkers,
+	}
+}
+
+// WithQuery overrides the policy with the query parameters followSymlinks,
+// hidden, archives, exclude (repeatable or comma separated, added to the configured
+// patterns), maxDepth and maxFiles
+func (p ScanPolicy) WithQuery(query url.Values) (ScanPolicy, error) {
+	var errs []error
+	parseBool := func(name string, target *bool) {
+		if raw := query.Get(name); raw != "" {
+			if err := setValue(target, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+	parseInt := func(name string, target *int) {
+		if raw := query.Get(name); raw != "" {
+			if err := setValue(target, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			} else if *target < 0 {
+				errs = append(errs, fmt.Errorf("%s cannot be negative", name))
+			}
+		}
+	}
+
+	parseBool("followSymlinks", &p.FollowSymlinks)
+	parseBool("hidden", &p.IncludeHidden)
+	parseBool("archives", &p.Archives)
+	parseInt("maxDepth", &p.MaxDepth)
+	parseInt("maxFiles", &p.MaxFiles)
+	for _, value := range query["exclude"] {
+		p.Exclude = append(p.Exclude, splitList(value)...)
+	}
+	if _, err := compileExcludes(p.Exclude); err != nil {
+		errs = append(errs, err)
+	}
+	return p, errors.Join(errs...)
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// excludePattern is one compiled gitignore-style pattern
+type excludePattern struct {
+	re      *regexp.Regexp
+	negate  bool // "!pattern" re-includes what an earlier pattern excluded
+	dirOnly bool // "pattern/" only matches directories
+}
+
+// excludeMatcher applies gitignore-style patterns to paths relative to the scan root
+type excludeMatcher []excludePattern
+
+// compileExcludes compiles patterns following gitignore rules: a pattern
+// without a slash matches a name at any depth, one with a leading or inner
+// slash is relative to the scanned directory, a trailing slash matches only
+// directories, * and ? do not cross slashes, ** matches any number of
+// directories, and ! negates. Later patterns take precedence.
+func compileExcludes(patterns []string) (excludeMatcher, error) {
+	var matcher excludeMatcher
+	for _, pattern := range patterns {
+		p := excludePattern{}
+		if strings.HasPrefix(pattern, "!") {
+			p.negate = true
+			pattern = pattern[1:]
+		}
+		if strings.HasSuffix(pattern, "/") {
+			p.dirOnly = true
+			pattern = strings.TrimRight(pattern, "/")
+		}
+		if pattern == "" {
+			continue
+		}
+
+		anchored := strings.Contains(pattern, "/")
+		pattern = strings.TrimPrefix(pattern, "/")
+
+		var expr strings.Builder
+		if anchored {
+			expr.WriteString("^")
+		} else {
+			expr.WriteString("(^|/)")
+		}
+		for i := 0; i < len(pattern); i++ {
+			c := pattern[i]
+			switch {
+			case strings.HasPrefix(pattern[i:], "**/"):
+				expr.WriteString("(.*/)?")
+				i += 2
+			case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+				expr.WriteString("/.*")
+				i += 2
+			case strings.HasPrefix(pattern[i:], "**"):
+				expr.WriteString(".*")
+				i++
+			case c == '*':
+				expr.WriteString("[^/]*")
+			case c == '?':
+				expr.WriteString("[^/]")
+			case c == '[':
+				end := strings.IndexByte(pattern[i:], ']')
+				if end < 0 {
+					return nil, fmt.Errorf("exclude pattern %q: unterminated [", pattern)
+				}
+				class := pattern[i+1 : i+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				expr.WriteString("[" + class + "]")
+				i += end
+			case c == '\\' && i+1 < len(pattern):
+				i++
+				expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			default:
+				expr.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		expr.WriteString("$")
+
+		re, err := regexp.Compile(expr.String())
+		if err != nil {
+			return nil, fmt.Errorf("exclude pattern %q: %v", pattern, err)
+		}
+		p.re = re
+		matcher = append(matcher, p)
+	}
+	return matcher, nil
+}
+
+// excluded reports whether the path, relative to the scan root with forward
+// slashes, is excluded
+func (m excludeMatcher) excluded(rel string, isDir bool) bool {
+	excluded := false
+	for _, p := range m {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(rel) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// scanPolicy returns the scan policy for a request: the configured defaults
+// overridden by query parameters
+func (s *Server) scanPolicy(r *http.Request) (ScanPolicy, error) {
+	return s.config.ScanPolicy().WithQuery(r.URL.Query())
+}
@@ -0,0 +1,117 @@
+// internal/app/scanpolicy_test.go
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExcludePatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		// A pattern without a slash matches a name at any depth
+		{"name at root", []string{"*.woff"}, "Font.woff", false, true},
+		{"name nested", []string{"*.woff"}, "a/b/Font.woff", false, true},
+		{"star stops at slash", []string{"Font*"}, "Fonts/Sans.ttf", false, false},
+		{"question mark", []string{"Font?.ttf"}, "x/Font1.ttf", false, true},
+		{"question mark not slash", []string{"a?b"}, "a/b", false, false},
+
+		// A leading or inner slash anchors the pattern to the scan root
+		{"leading slash at root", []string{"/Old"}, "Old", true, true},
+		{"leading slash nested", []string{"/Old"}, "a/Old", true, false},
+		{"inner slash at root", []string{"src/Old"}, "src/Old", true, true},
+		{"inner slash nested", []string{"src/Old"}, "x/src/Old", true, false},
+
+		// ** crosses directories
+		{"leading ** at root", []string{"**/cache"}, "cache", true, true},
+		{"leading ** nested", []string{"**/cache"}, "a/b/cache", true, true},
+		{"trailing ** contents", []string{"build/**"}, "build/a/b.ttf", false, true},
+		{"trailing ** not itself", []string{"build/**"}, "build", true, false},
+		{"inner ** none", []string{"a/**/b.ttf"}, "a/b.ttf", false, true},
+		{"inner ** many", []string{"a/**/b.ttf"}, "a/x/y/b.ttf", false, true},
+		{"inner ** anchored", []string{"a/**/b.ttf"}, "z/a/x/b.ttf", false, false},
+
+		// A trailing slash only matches directories
+		{"dir only directory", []string{"tmp/"}, "x/tmp", true, true},
+		{"dir only file", []string{"tmp/"}, "x/tmp", false, false},
+
+		// ! re-includes, and later patterns win
+		{"negated", []string{"*.ttf", "!Keep.ttf"}, "a/Keep.ttf", false, false},
+		{"negation before exclude", []string{"!Keep.ttf", "*.ttf"}, "a/Keep.ttf", false, true},
+		{"negation other file", []string{"*.ttf", "!Keep.ttf"}, "a/Drop.ttf", false, true},
+
+		// Regex metacharacters are literal
+		{"dot literal", []string{"a.ttf"}, "abttf", false, false},
+		{"plus literal", []string{"C++"}, "C++", true, true},
+		{"parens literal", []string{"Font (old).ttf"}, "Font (old).ttf", false, true},
+		{"dollar literal", []string{"$temp"}, "x/$temp", true, true},
+		{"braces literal", []string{"a{1,2}"}, "aa", false, false},
+		{"escaped star", []string{`\*.ttf`}, "*.ttf", false, true},
+		{"escaped star literal only", []string{`\*.ttf`}, "Sans.ttf", false, false},
+		{"class", []string{"Font[0-9].ttf"}, "Font7.ttf", false, true},
+		{"negated class", []string{"Font[!0-9].ttf"}, "Font7.ttf", false, false},
+
+		// Paths inside archives are matched like any other path
+		{"archive member name", []string{"*.ttf"}, "Fonts.zip!/Regular/Sans.ttf", false, true},
+		{"archive itself", []string{"Fonts.zip"}, "Fonts.zip", false, true},
+		{"archive name not member", []string{"Fonts.zip"}, "Fonts.zip!/Sans.ttf", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := compileExcludes(tt.patterns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matcher.excluded(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("%q excluding %q (dir %v) = %v, want %v", tt.patterns, tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludePatternErrors(t *testing.T) {
+	if _, err := compileExcludes([]string{"Font[0-9.ttf"}); err == nil {
+		t.Error("unterminated [ compiled")
+	}
+	matcher, err := compileExcludes([]string{"", "!", "/"})
+	if err != nil || len(matcher) != 0 {
+		t.Errorf("empty patterns compiled to %v, %v", matcher, err)
+	}
+}
+
+// Exclude patterns apply to an archive, and the directories it is in, before
+// its members are listed
+func TestExcludeArchives(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"old", "new"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	members := map[string][]byte{"Regular/Sans.ttf": []byte("font")}
+	writeArchive(t, filepath.Join(root, "old", "Fonts.zip"), members)
+	writeArchive(t, filepath.Join(root, "new", "Fonts.zip"), members)
+	writeArchive(t, filepath.Join(root, "new", "Skip.zip"), members)
+
+	policy := ScanPolicy{Archives: true, Exclude: []string{"old/", "Skip.zip"}}
+	var found []string
+	if _, err := walkFonts(context.Background(), root, policy, walkOptions{}, func(path string) {
+		rel, _ := filepath.Rel(root, path)
+		found = append(found, filepath.ToSlash(rel))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(found)
+	if want := "new/Fonts.zip!/Regular/Sans.ttf"; strings.Join(found, ",") != want {
+		t.Errorf("found %v, want %s", found, want)
+	}
+}
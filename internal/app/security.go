@@ -2,7 +2,13 @@
 package app
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -31,7 +37,7 @@ func isPathAllowed(path string) bool {
 
 	// Prevent directory traversal
 	if strings.Contains(path, "..") {
-		logging.Error("Potential directory traversal attempt", "security_check", path, fmt.Errorf("path contains '..'"))
+		logging.Warn("Potential directory traversal attempt", "security_check", path, fmt.Errorf("path contains '..'"))
 		return false
 	}
 
@@ -39,7 +45,7 @@ func isPathAllowed(path string) bool {
 	fileName := filepath.Base(path)
 	ext := strings.ToLower(filepath.Ext(fileName))
 	if !allowedExts[ext] {
-		logging.Error("Invalid file extension", "security_check", path,
+		logging.Warn("Invalid file extension", "security_check", path,
 			fmt.Errorf("extension %s not allowed", ext))
 		return false
 	}
@@ -117,6 +123,123 @@ func ValidateFontDirectory(dir string) error {
 	}
 	file.Close()
 
-	logging.Info("Directory validation passed", "validate_dir", dir)
+	logging.Debug("Directory validation passed", "validate_dir", dir)
 	return nil
 }
+
+// accessTokenCookie holds the per-launch access token in LAN mode
+const accessTokenCookie = "gofindmyfonts_token"
+
+// newAccessToken returns a random token for this launch of the server
+func newAccessToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// requestHostname returns the host of the Host header without the port
+func requestHostname(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	return strings.Trim(host, "[]")
+}
+
+// checkOrigin rejects requests made by other sites. Browsers send Origin on
+// cross-origin fetches and on every POST, PUT and DELETE; it must match the
+// host the request was sent to. Sec-Fetch-Site catches cross-site GETs without
+// Origin, such as <img> or <script> tags, while still allowing a user to follow
+// a link to the interface.
+func checkOrigin(r *http.Request) error {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			return fmt.Errorf("origin %q does not match host %q", origin, r.Host)
+		}
+		return nil
+	}
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" && r.Header.Get("Sec-Fetch-Mode") != "navigate" {
+		return fmt.Errorf("cross-site %s request", r.Header.Get("Sec-Fetch-Mode"))
+	}
+	return nil
+}
+
+// hasAccessToken reports whether the request carries the access token as a
+// cookie or an Authorization: Bearer header
+func (s *Server) hasAccessToken(r *http.Request) bool {
+	var token string
+	if cookie, err := r.Cookie(accessTokenCookie); err == nil {
+		token = cookie.Value
+	} else if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// guard wraps every route with the origin check, basic auth or the access
+// token check, and in shared mode the read-only check. Without credentials the
+// Host header must name this machine, so a DNS rebinding page cannot pass the
+// origin check by sharing our origin.
+func (s *Server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.users == nil && !s.requiresToken() && !isLoopbackHost(requestHostname(r)) {
+			logging.InfoContext(r.Context(), fmt.Sprintf("Rejected request for host %s", r.Host), "guard", r.URL.Path)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if err := checkOrigin(r); err != nil {
+			logging.WarnContext(r.Context(), "Rejected cross-origin request", "guard", r.URL.Path, err)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if s.users != nil {
+			user, ok := s.checkBasicAuth(r)
+			if !ok {
+				logging.InfoContext(r.Context(), "Rejected request without valid credentials", "guard", r.URL.Path)
+				w.Header().Set("WWW-Authenticate", `Basic realm="gofindmyfonts", charset="UTF-8"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+		}
+
+		if s.requiresToken() && !s.hasAccessToken(r) {
+			// The launch URL carries the token once; keep it in a cookie and
+			// drop it from the address bar
+			token := r.URL.Query().Get("token")
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				logging.InfoContext(r.Context(), "Rejected request without access token", "guard", r.URL.Path)
+				http.Error(w, "Access token required. Open the address printed when the server started.", http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     accessTokenCookie,
+				Value:    s.token,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+			if r.Method == http.MethodGet {
+				query := r.URL.Query()
+				query.Del("token")
+				target := *r.URL
+				target.RawQuery = query.Encode()
+				http.Redirect(w, r, target.RequestURI(), http.StatusSeeOther)
+				return
+			}
+		}
+
+		if s.config.Shared && !readOnlyAllowed(r) {
+			logging.InfoContext(r.Context(), fmt.Sprintf("Rejected %s in shared mode", r.Method), "guard", r.URL.Path)
+			http.Error(w, "This server is read-only", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,15 +2,15 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"mime"
 	"net/http"
-	"net/url"
-	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bradsec/gofindmyfonts/internal/logging"
@@ -33,15 +33,72 @@ func (e *FontError) Error() string {
 
 type Server struct {
 	generator *PreviewGenerator
+	library   *LibraryStore
 	config    *Config
+	token     string            // Access token, required in LAN mode or when configured
+	users     map[string]string // Basic auth users from the users file
+	audit     *AuditLog         // Download log in shared mode
+	etags     *etagCache        // Content hashes of downloaded fonts
+
+	mu         sync.Mutex
+	httpServer *http.Server
+	stopped    bool // Shutdown has been called; Start no longer listens
 }
 
 // NewServer creates a new Server instance
-func NewServer(generator *PreviewGenerator) *Server {
-	return &Server{
+func NewServer(generator *PreviewGenerator, library *LibraryStore) (*Server, error) {
+	config := generator.config
+	s := &Server{
 		generator: generator,
-		config:    LoadConfig(),
+		library:   library,
+		config:    config,
+		token:     config.AccessToken,
+		etags:     newETagCache(),
 	}
+
+	if s.token == "" {
+		token, err := newAccessToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate access token: %v", err)
+		}
+		s.token = token
+	}
+
+	if config.UsersFile != "" {
+		users, err := loadUsers(config.UsersFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load users: %v", err)
+		}
+		s.users = users
+	}
+
+	if config.Shared {
+		audit, err := OpenAuditLog(filepath.Join(config.LogDir, "downloads.log"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open download audit log: %v", err)
+		}
+		s.audit = audit
+	}
+
+	return s, nil
+}
+
+// requiresToken reports whether requests must carry the access token: in LAN
+// mode, or whenever a fixed token is configured. Basic auth replaces the token.
+func (s *Server) requiresToken() bool {
+	if s.users != nil {
+		return false
+	}
+	return s.config.RequiresToken() || s.config.AccessToken != ""
+}
+
+// LaunchURL is the address to open the interface with. In LAN mode it carries
+// the access token, which the browser keeps as a cookie after the first visit.
+func (s *Server) LaunchURL() string {
+	if !s.requiresToken() {
+		return s.config.URL() + "/"
+	}
+	return s.config.URL() + "/?token=" + s.token
 }
 
 func (s *Server) Start() error {
@@ -56,38 +113,103 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/", s.handleIndex)
 	mux.HandleFunc("/favicon.ico", s.handleFavicon)
 	mux.HandleFunc("/generate", s.handleGenerate)
+	mux.HandleFunc("/api/scan", s.handleScan)
 	mux.HandleFunc("/progress", s.handleProgress)
 	mux.HandleFunc("/download", s.handleFontDownload)
 	mux.HandleFunc("/download-all", s.handleDownloadAll)
+	mux.HandleFunc("/api/jobs", s.handleJobs)
+	mux.HandleFunc("/api/jobs/", s.handleJobs)
+	mux.HandleFunc("/api/quarantine", s.handleQuarantine)
+	mux.HandleFunc("/api/metrics", s.handleFontMetrics)
+	mux.HandleFunc("/api/fallback", s.handleFallback)
+	mux.HandleFunc("/api/library", s.handleLibrary)
+	mux.HandleFunc("/api/library/fonts", s.handleLibraryFont)
+	mux.HandleFunc("/api/library/collections", s.handleLibraryCollections)
+	mux.HandleFunc("/api/library/export", s.handleLibraryExport)
+	mux.HandleFunc("/api/library/import", s.handleLibraryImport)
+	mux.HandleFunc("/diagnostics", s.handleDiagnostics)
+	mux.HandleFunc("/api/diagnostics", s.handleDiagnostics)
+	mux.HandleFunc("/metrics", s.handleMetrics)
 
 	// Serve static files
 	fs := http.FileServer(http.Dir(s.config.StaticDir))
 	mux.Handle("/static/", http.StripPrefix("/static/", fs))
 
 	// Start server with increased timeouts
-	addr := ":" + s.config.Port
 	server := &http.Server{
-		Addr:         addr,
-		Handler:      mux,
+		Addr:         s.config.Addr(),
+		Handler:      s.accessLog(s.guard(mux)),
 		ReadTimeout:  60 * time.Second,
 		WriteTimeout: 300 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
 
-	url := fmt.Sprintf("http://localhost%s", addr)
-	logging.Info(fmt.Sprintf("Server starting on %s", url), "server_start", "")
+	// A shutdown that arrived before the listener was set up wins
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.httpServer = server
+	s.mu.Unlock()
+
+	logging.Info(fmt.Sprintf("Server starting on %s", s.config.URL()), "server_start", "")
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops the server gracefully. Progress streams are closed, then new
+// connections are refused while active requests such as downloads, zips and
+// scans finish, followed by any conversions still running. If ctx expires
+// first, remaining scans and conversions are killed and connections closed.
+// A Start that has not begun listening yet returns without serving.
+func (s *Server) Shutdown(ctx context.Context) error {
+	logging.InfoContext(ctx, "Shutting down server", "server_shutdown", "")
+	s.generator.events.close()
+
+	var errs []error
+	s.mu.Lock()
+	server := s.httpServer
+	s.stopped = true
+	s.mu.Unlock()
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			logging.ErrorContext(ctx, "Requests still active at shutdown deadline, closing connections", "server_shutdown", "", err)
+			s.generator.cancel()
+			server.Close()
+			errs = append(errs, err)
+		}
+	}
+
+	if err := s.generator.Drain(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := s.audit.Close(); err != nil {
+		errs = append(errs, err)
+	}
 
-	return server.ListenAndServe()
+	if len(errs) == 0 {
+		logging.InfoContext(ctx, "Server stopped", "server_shutdown", "")
+	}
+	return errors.Join(errs...)
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
-		logging.Info(fmt.Sprintf("Not found: %s", r.URL.Path), "handle_index", r.URL.Path)
+		logging.InfoContext(r.Context(), fmt.Sprintf("Not found: %s", r.URL.Path), "handle_index", r.URL.Path)
 		http.NotFound(w, r)
 		return
 	}
-	if err := templates.RenderIndex(w); err != nil {
-		logging.Error("Error rendering index", "handle_index", "", err)
+	data := templates.IndexData{Shared: s.config.Shared}
+	for _, dir := range s.config.Libraries {
+		data.Libraries = append(data.Libraries, dir.Name)
+	}
+	if err := templates.RenderIndex(w, data); err != nil {
+		logging.ErrorContext(r.Context(), "Error rendering index", "handle_index", "", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -96,25 +218,15 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleFavicon(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "image/x-icon")
 	if err := templates.ServeFavicon(w); err != nil {
-		logging.Error("Error serving favicon", "handle_favicon", "", err)
+		logging.ErrorContext(r.Context(), "Error serving favicon", "handle_favicon", "", err)
 		http.Error(w, "Favicon not found", http.StatusNotFound)
 		return
 	}
 }
 
 func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if r.Method != http.MethodGet {
-		logging.Info(fmt.Sprintf("Invalid method: %s", r.Method), "handle_generate", "")
+		logging.InfoContext(r.Context(), fmt.Sprintf("Invalid method: %s", r.Method), "handle_generate", "")
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": "Method not allowed",
@@ -122,9 +234,17 @@ func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fontDir := r.URL.Query().Get("fontDir")
+	fontDir, err := s.resolveFontDir(r)
+	if err != nil {
+		logging.ErrorContext(r.Context(), "Font directory not allowed", "handle_generate", "", err)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Invalid directory: %v", err),
+		})
+		return
+	}
 	if fontDir == "" {
-		logging.Info("Missing font directory in request", "handle_generate", "")
+		logging.InfoContext(r.Context(), "Missing font directory in request", "handle_generate", "")
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": "Please enter a directory path",
@@ -134,7 +254,7 @@ func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 
 	// Validate directory exists and is accessible
 	if err := ValidateFontDirectory(fontDir); err != nil {
-		logging.Error("Invalid font directory", "handle_generate", fontDir, err)
+		logging.ErrorContext(r.Context(), "Invalid font directory", "handle_generate", fontDir, err)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": fmt.Sprintf("Invalid directory: %v", err),
@@ -142,10 +262,40 @@ func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	policy, err := s.scanPolicy(r)
+	if err != nil {
+		logging.ErrorContext(r.Context(), "Invalid scan options", "handle_generate", fontDir, err)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Invalid scan options: %v", err),
+		})
+		return
+	}
+
+	// Bind the scan to the request so closing the tab or cancelling the job stops it
+	job, ctx, err := s.generator.StartJob(r.Context(), r.URL.Query().Get("job"), fontDir)
+	if err != nil {
+		logging.ErrorContext(r.Context(), "Failed to start job", "handle_generate", fontDir, err)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Unable to start scan: %v", err),
+		})
+		return
+	}
+	defer s.generator.FinishJob(job)
+
 	// Process fonts
-	previews, err := s.generator.ProcessFonts(fontDir)
+	result, err := s.generator.ProcessFonts(ctx, fontDir, policy, nil)
 	if err != nil {
-		logging.Error("Error processing fonts", "handle_generate", fontDir, err)
+		if ctx.Err() != nil {
+			logging.InfoContext(ctx, "Scan cancelled", "handle_generate", fontDir)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Scan cancelled",
+			})
+			return
+		}
+		logging.ErrorContext(ctx, "Error processing fonts", "handle_generate", fontDir, err)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": fmt.Sprintf("Error processing fonts: %v", err),
@@ -153,10 +303,17 @@ func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Filter by OpenType features, e.g. ?feature=smcp,ss01
+	if features := parseFeatureList(r.URL.Query()["feature"]); len(features) > 0 {
+		result.Fonts = FilterByFeatures(result.Fonts, features)
+		result.Refresh()
+		logging.InfoContext(ctx, fmt.Sprintf("Filtered to %d fonts supporting %v", len(result.Fonts), features), "handle_generate", fontDir)
+	}
+
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(previews); err != nil {
-		logging.Error("Error encoding response", "handle_generate", "", err)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		logging.ErrorContext(ctx, "Error encoding response", "handle_generate", "", err)
 		if !isConnectionClosed(err) {
 			json.NewEncoder(w).Encode(map[string]string{
 				"error": "Error encoding response",
@@ -165,94 +322,94 @@ func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		logging.Info("Streaming not supported", "handle_progress", "")
-		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
-		return
-	}
-
-	// Get progress channel from generator
-	progressChan := s.generator.GetProgressChan()
-
-	// Send initial message
-	fmt.Fprintf(w, "data: Initializing progress monitoring...\n\n")
-	flusher.Flush()
-
-	// Stream updates to client
-	for {
-		select {
-		case msg, ok := <-progressChan:
-			if !ok {
-				return
-			}
-			fmt.Fprintf(w, "data: %s\n\n", msg)
-			flusher.Flush()
-		case <-r.Context().Done():
-			return
-		}
-	}
-}
-
 func (s *Server) handleFontDownload(w http.ResponseWriter, r *http.Request) {
 	fontPath := r.URL.Query().Get("path")
 	if fontPath == "" {
-		logging.Info("Download attempted with empty path", "handle_download", "")
+		logging.InfoContext(r.Context(), "Download attempted with empty path", "handle_download", "")
 		http.Error(w, "No font path specified", http.StatusBadRequest)
 		return
 	}
 
 	// Clean and validate the path
 	fontPath = filepath.Clean(fontPath)
-	if !isPathAllowed(fontPath) {
-		logging.Info("Access denied to path", "handle_download", fontPath)
+	if !isPathAllowed(fontPath) || !s.fontPathAllowed(fontPath) {
+		logging.InfoContext(r.Context(), "Access denied to path", "handle_download", fontPath)
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
 
-	// Open the file
-	file, err := os.Open(fontPath)
+	// Convert on first request when a format other than the original is asked for
+	fileName := filepath.Base(fontPath)
+	if format := normalizeFormat(r.URL.Query().Get("format")); format != "" && format != strings.ToLower(filepath.Ext(fontPath)) {
+		convertedPath, cached, err := s.generator.Convert(r.Context(), fontPath, format)
+		if err != nil {
+			if r.Context().Err() != nil {
+				logging.InfoContext(r.Context(), "Download cancelled during conversion", "handle_download", fontPath)
+				return
+			}
+			logging.ErrorContext(r.Context(), "On-demand conversion failed", "handle_download", fontPath, err)
+			var processErr *FontProcessError
+			if errors.As(err, &processErr) && processErr.Op == "convert" {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Conversion failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !cached {
+			logging.InfoContext(r.Context(), fmt.Sprintf("Converted to %s on demand", format), "handle_download", fontPath)
+		}
+		fileName = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + format
+		fontPath = convertedPath
+	}
+
+	// Open the file, or read the member out of its archive
+	file, fileInfo, err := s.generator.files.open(fontPath)
 	if err != nil {
-		logging.Error("Failed to open font file", "handle_download", fontPath, err)
+		logging.ErrorContext(r.Context(), "Failed to open font file", "handle_download", fontPath, err)
 		http.Error(w, "Font file not found or not accessible", http.StatusNotFound)
 		return
 	}
 	defer file.Close()
 
-	// Get file info
-	fileInfo, err := file.Stat()
-	if err != nil {
-		logging.Error("Error reading font file stats", "handle_download", fontPath, err)
-		http.Error(w, "Error reading font file", http.StatusInternalServerError)
-		return
+	// The requested filename is client input; it only names the download
+	if qFileName := r.URL.Query().Get("filename"); qFileName != "" {
+		fileName = sanitizeFilename(qFileName, fileName)
 	}
 
-	// Set filename for download
-	fileName := filepath.Base(fontPath)
-	if qFileName := r.URL.Query().Get("filename"); qFileName != "" {
-		if decodedName, err := url.QueryUnescape(qFileName); err == nil {
-			fileName = decodedName
-		}
+	// A strong ETag from the content lets browsers revalidate with If-None-Match
+	if etag, err := s.etags.get(file, fontPath, fileInfo); err == nil {
+		w.Header().Set("ETag", etag)
+	} else {
+		logging.ErrorContext(r.Context(), "Error hashing font file", "handle_download", fontPath, err)
 	}
 
 	// Set headers for download
-	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
-	w.Header().Set("Content-Type", getMIMEType(filepath.Ext(fileName)))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
+	w.Header().Set("Content-Disposition", contentDisposition(fileName))
+	w.Header().Set("Content-Type", getMIMEType(filepath.Ext(fontPath)))
 	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.config.PreviewCacheTime.Seconds())))
 
-	// Stream the file
-	if _, err := io.Copy(w, file); err != nil {
-		if !isConnectionClosed(err) {
-			logging.Error("Error streaming file", "handle_download", fontPath, err)
+	// ServeContent answers conditional requests with 304 and Range requests with 206
+	cw := &countingWriter{ResponseWriter: w}
+	http.ServeContent(cw, r, fileName, fileInfo.ModTime(), file)
+	if cw.written > 0 {
+		s.audit.Record(r, "download", fontPath, fileName, cw.written)
+		downloadsTotal.Inc("font")
+		downloadBytes.Add(float64(cw.written), "font")
+	}
+}
+
+// parseFeatureList splits comma separated feature tag parameters into individual tags
+func parseFeatureList(values []string) []string {
+	var features []string
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				features = append(features, tag)
+			}
 		}
 	}
+	return features
 }
 
 func getMIMEType(ext string) string {
@@ -0,0 +1,82 @@
+// internal/app/server_test.go
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server over a generator with temporary directories
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := NewServer(newTestGenerator(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// A job that cannot start is reported to the client, whether its ID is
+// invalid or already in use, rather than panicking while logging the failure
+func TestStartJobFailure(t *testing.T) {
+	s := newTestServer(t)
+	fontDir := t.TempDir()
+
+	running, _, err := s.generator.StartJob(context.Background(), "taken", fontDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.generator.FinishJob(running)
+
+	handlers := map[string]http.HandlerFunc{
+		"/generate": s.handleGenerate,
+		"/api/scan": s.handleScan,
+	}
+	for path, handler := range handlers {
+		for _, job := range []string{"bad!id", "taken"} {
+			t.Run(path+"?job="+job, func(t *testing.T) {
+				query := url.Values{"fontDir": {fontDir}, "job": {job}}
+				r := httptest.NewRequest(http.MethodGet, path+"?"+query.Encode(), nil)
+				w := httptest.NewRecorder()
+				handler(w, r)
+
+				if body := w.Body.String(); !strings.Contains(body, "Unable to start scan") {
+					t.Errorf("response %d %q does not report the failed job", w.Code, body)
+				}
+			})
+		}
+	}
+}
+
+// A shutdown signal that arrives before Start has set up its listener stops
+// the server instead of leaving Start serving with nothing to stop it
+func TestShutdownBeforeStart(t *testing.T) {
+	s := newTestServer(t)
+	s.config.BindAddress = "127.0.0.1"
+	s.config.Port = "0"
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan error, 1)
+	go func() { started <- s.Start() }()
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Errorf("Start after Shutdown returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		s.mu.Lock()
+		server := s.httpServer
+		s.mu.Unlock()
+		if server != nil {
+			server.Close()
+		}
+		t.Fatal("Start kept serving after Shutdown")
+	}
+}
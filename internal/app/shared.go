@@ -0,0 +1,238 @@
+// internal/app/shared.go
+package app
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+// userKey is the context key of the user authenticated with basic auth
+type userKey struct{}
+
+// contextUser returns the basic auth user of the request ctx belongs to, or ""
+// when the server has no users file
+func contextUser(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// requestUser names who made a request for the audit log: the basic auth user,
+// or "anonymous" when the server has no users file
+func requestUser(r *http.Request) string {
+	if user := contextUser(r.Context()); user != "" {
+		return user
+	}
+	return "anonymous"
+}
+
+// loadUsers reads a users file of "name:password" lines. A password may be
+// given as "sha256:<hex digest>" instead of in plain text. Blank lines and
+// lines starting with # are ignored.
+func loadUsers(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, password, ok := strings.Cut(text, ":")
+		if !ok || name == "" || password == "" {
+			return nil, fmt.Errorf("%s:%d: expected name:password", path, line)
+		}
+		if !strings.HasPrefix(password, "sha256:") {
+			sum := sha256.Sum256([]byte(password))
+			password = "sha256:" + hex.EncodeToString(sum[:])
+		}
+		users[name] = password
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%s: no users defined", path)
+	}
+	return users, nil
+}
+
+// checkBasicAuth returns the user named by valid basic auth credentials
+func (s *Server) checkBasicAuth(r *http.Request) (string, bool) {
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(password))
+	given := "sha256:" + hex.EncodeToString(sum[:])
+	want, known := s.users[name]
+	if !known {
+		// Compare anyway so unknown names take as long as wrong passwords
+		want = "sha256:" + strings.Repeat("0", 64)
+	}
+	match := subtle.ConstantTimeCompare([]byte(strings.ToLower(given)), []byte(strings.ToLower(want))) == 1
+	return name, known && match
+}
+
+// readOnlyAllowed reports whether a request may be served in shared mode:
+// reading, downloading and cancelling the user's own scans, but no changes to
+// the library or quarantine
+func readOnlyAllowed(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		return r.URL.Path == "/download-all"
+	case http.MethodDelete:
+		return strings.HasPrefix(r.URL.Path, "/api/jobs/")
+	}
+	return false
+}
+
+// resolveFontDir returns the directory a scan request asks for: the library
+// named by ?library=, or outside shared mode the path in ?fontDir=
+func (s *Server) resolveFontDir(r *http.Request) (string, error) {
+	if name := r.URL.Query().Get("library"); name != "" {
+		path, ok := s.config.Libraries.Find(name)
+		if !ok {
+			return "", fmt.Errorf("unknown library %q", name)
+		}
+		return path, nil
+	}
+	if s.config.Shared && r.URL.Query().Get("fontDir") != "" {
+		return "", fmt.Errorf("this server only serves its configured libraries")
+	}
+	return r.URL.Query().Get("fontDir"), nil
+}
+
+// fontPathAllowed reports whether a font file may be read. Outside shared mode
+// any font path is allowed; in shared mode it, or the archive it is in, must be
+// inside a library or the converted font cache, after resolving symlinks.
+func (s *Server) fontPathAllowed(path string) bool {
+	if !s.config.Shared {
+		return true
+	}
+	if archive, _, ok := splitArchivePath(path); ok {
+		path = archive
+	}
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return false
+	}
+
+	roots := []string{filepath.Join(s.config.StaticDir, "converted")}
+	for _, dir := range s.config.Libraries {
+		roots = append(roots, dir.Path)
+	}
+	for _, root := range roots {
+		resolvedRoot, err := resolvePath(root)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(resolvedRoot, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	logging.Info("Path outside shared libraries", "shared_path", path)
+	return false
+}
+
+// resolvePath returns the absolute path with symlinks resolved
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// AuditEntry records a font delivered to a user in shared mode
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Remote string    `json:"remote"`
+	Action string    `json:"action"` // "download" or "download_all"
+	Path   string    `json:"path"`   // Font file on the server
+	Name   string    `json:"name"`   // File name the user received
+	Bytes  int64     `json:"bytes"`
+}
+
+// AuditLog appends download records as JSON lines for license compliance
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenAuditLog opens the audit log at path for appending
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{file: file}, nil
+}
+
+// Record appends an entry for a download made by r. It does nothing on a nil log.
+func (a *AuditLog) Record(r *http.Request, action, path, name string, bytes int64) {
+	if a == nil {
+		return
+	}
+	entry := AuditEntry{
+		Time:   time.Now().UTC(),
+		User:   requestUser(r),
+		Remote: r.RemoteAddr,
+		Action: action,
+		Path:   path,
+		Name:   name,
+		Bytes:  bytes,
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		logging.ErrorContext(r.Context(), "Failed to encode audit entry", "audit", path, err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return
+	}
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		logging.ErrorContext(r.Context(), "Failed to write audit entry", "audit", path, err)
+	}
+}
+
+// Close closes the audit log. It does nothing on a nil log.
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
@@ -0,0 +1,160 @@
+// internal/app/stream.go
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+// Scan stream event types
+const (
+	ScanEventFont    = "font"    // A font was found; its original formats are available
+	ScanEventUpdate  = "update"  // A conversion for a previously sent font completed or failed
+	ScanEventSummary = "summary" // The scan finished; always the last event of a successful scan
+	ScanEventError   = "error"   // The scan failed or was cancelled
+)
+
+// scanStreamWriteTimeout bounds how long a single event write may block
+const scanStreamWriteTimeout = 30 * time.Second
+
+// ScanObserver receives fonts while a scan is running. Methods are called from
+// the conversion workers and must be safe for concurrent use.
+type ScanObserver interface {
+	FontFound(font FontPreview)
+	FontUpdated(font FontPreview)
+}
+
+// ScanEvent is one line of the /api/scan NDJSON stream
+type ScanEvent struct {
+	Type    string       `json:"type"`
+	Font    *FontPreview `json:"font,omitempty"`
+	Summary *ScanSummary `json:"summary,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// ndjsonStream writes scan events as newline-delimited JSON, flushing after each line
+type ndjsonStream struct {
+	ctx      context.Context // Scan job context; write errors are logged with its job ID
+	mu       sync.Mutex
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	features []string
+	failed   bool
+}
+
+func newNDJSONStream(ctx context.Context, w http.ResponseWriter, features []string) *ndjsonStream {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	return &ndjsonStream{ctx: ctx, w: w, rc: http.NewResponseController(w), features: features}
+}
+
+func (s *ndjsonStream) FontFound(font FontPreview) {
+	if supportsFeatures(font, s.features) {
+		s.send(ScanEvent{Type: ScanEventFont, Font: &font})
+	}
+}
+
+func (s *ndjsonStream) FontUpdated(font FontPreview) {
+	if supportsFeatures(font, s.features) {
+		s.send(ScanEvent{Type: ScanEventUpdate, Font: &font})
+	}
+}
+
+// send writes a single event. After the first write error, e.g. when the client
+// went away, further events are dropped; the request context cancels the scan.
+func (s *ndjsonStream) send(event ScanEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failed {
+		return
+	}
+
+	// Scans can outlast the server's write timeout, so extend it per event
+	s.rc.SetWriteDeadline(time.Now().Add(scanStreamWriteTimeout))
+
+	if err := json.NewEncoder(s.w).Encode(event); err != nil {
+		s.failed = true
+		if !isConnectionClosed(err) {
+			logging.ErrorContext(s.ctx, "Error writing scan event", "scan_stream", event.Type, err)
+		}
+		return
+	}
+	if err := s.rc.Flush(); err != nil {
+		s.failed = true
+		logging.ErrorContext(s.ctx, "Error flushing scan event", "scan_stream", event.Type, err)
+	}
+}
+
+// handleScan scans a directory like /generate but streams the results as NDJSON:
+// a "font" event per font as soon as it is found, an "update" event whenever one
+// of its conversions finishes, then a final "summary" or "error" event.
+func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logging.InfoContext(r.Context(), fmt.Sprintf("Invalid method: %s", r.Method), "handle_scan", "")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	fontDir, err := s.resolveFontDir(r)
+	if err != nil {
+		logging.ErrorContext(r.Context(), "Font directory not allowed", "handle_scan", "", err)
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("Invalid directory: %v", err)})
+		return
+	}
+	if fontDir == "" {
+		logging.InfoContext(r.Context(), "Missing font directory in request", "handle_scan", "")
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Please enter a directory path"})
+		return
+	}
+
+	if err := ValidateFontDirectory(fontDir); err != nil {
+		logging.ErrorContext(r.Context(), "Invalid font directory", "handle_scan", fontDir, err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid directory: %v", err)})
+		return
+	}
+
+	policy, err := s.scanPolicy(r)
+	if err != nil {
+		logging.ErrorContext(r.Context(), "Invalid scan options", "handle_scan", fontDir, err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid scan options: %v", err)})
+		return
+	}
+
+	job, ctx, err := s.generator.StartJob(r.Context(), r.URL.Query().Get("job"), fontDir)
+	if err != nil {
+		logging.ErrorContext(r.Context(), "Failed to start job", "handle_scan", fontDir, err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Unable to start scan: %v", err)})
+		return
+	}
+	defer s.generator.FinishJob(job)
+
+	features := parseFeatureList(r.URL.Query()["feature"])
+	stream := newNDJSONStream(ctx, w, features)
+	w.WriteHeader(http.StatusOK)
+
+	result, err := s.generator.ProcessFonts(ctx, fontDir, policy, stream)
+	if err != nil {
+		if ctx.Err() != nil {
+			logging.InfoContext(ctx, "Scan cancelled", "handle_scan", fontDir)
+			stream.send(ScanEvent{Type: ScanEventError, Error: "Scan cancelled"})
+			return
+		}
+		logging.ErrorContext(ctx, "Error processing fonts", "handle_scan", fontDir, err)
+		stream.send(ScanEvent{Type: ScanEventError, Error: fmt.Sprintf("Error processing fonts: %v", err)})
+		return
+	}
+
+	if len(features) > 0 {
+		result.Fonts = FilterByFeatures(result.Fonts, features)
+		result.Refresh()
+	}
+	stream.send(ScanEvent{Type: ScanEventSummary, Summary: &result.Summary})
+	logging.InfoContext(ctx, fmt.Sprintf("Streamed %d fonts", len(result.Fonts)), "handle_scan", fontDir)
+}
@@ -0,0 +1,136 @@
+// internal/app/stream_test.go
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+// The first font event of a scan stream, and its font_found progress event,
+// arrive while a slow directory is still being read
+func TestScanStreamsBeforeWalkEnds(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"Sans.ttf", "slow/Late.ttf"} {
+		writeFont(t, filepath.Join(root, filepath.FromSlash(name)))
+	}
+
+	release := make(chan struct{})
+	released := false
+	defer func() {
+		if !released {
+			close(release)
+		}
+	}()
+	s := newTestServer(t)
+	s.generator.readDir = blockingReadDir(release)
+	progress, _ := s.generator.events.subscribe("streamjob", 0)
+	defer s.generator.events.unsubscribe(progress)
+
+	server := httptest.NewServer(http.HandlerFunc(s.handleScan))
+	defer server.Close()
+	query := url.Values{"fontDir": {root}, "job": {"streamjob"}}
+	resp, err := http.Get(server.URL + "/api/scan?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	events := make(chan ScanEvent)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var event ScanEvent
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				t.Errorf("%v: %s", err, scanner.Text())
+				return
+			}
+			events <- event
+		}
+	}()
+
+	timeout := time.After(5 * time.Second)
+	select {
+	case event := <-events:
+		if event.Type != ScanEventFont || event.Font == nil || event.Font.Name != "Sans" {
+			t.Fatalf("first event is %+v, want the Sans font", event)
+		}
+	case <-timeout:
+		t.Fatal("no font event while the slow directory was blocked")
+	}
+	for found := false; !found; {
+		select {
+		case event := <-progress.events:
+			found = event.Type == EventFontFound
+		case <-timeout:
+			t.Fatal("no font_found event while the slow directory was blocked")
+		}
+	}
+
+	close(release)
+	released = true
+	var last ScanEvent
+	fonts := 1
+	for event := range events {
+		if event.Type == ScanEventFont {
+			fonts++
+		}
+		last = event
+	}
+	if last.Type != ScanEventSummary || fonts != 2 {
+		t.Errorf("stream ended with %+v after %d fonts, want a summary after 2", last, fonts)
+	}
+}
+
+// failingWriter is a response whose body cannot be written
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+// A scan event that cannot be written is logged once, with the scan's job ID
+func TestScanStreamWriteErrorLogsJobID(t *testing.T) {
+	pg := newTestGenerator(t)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	job, ctx, err := pg.StartJob(logging.NewContext(context.Background(), logger), "streamlog", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pg.FinishJob(job)
+
+	stream := newNDJSONStream(ctx, failingWriter{httptest.NewRecorder()}, nil)
+	stream.FontFound(FontPreview{Name: "Sans"})
+	stream.FontFound(FontPreview{Name: "Serif"})
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("%v: %s", err, scanner.Text())
+		}
+		if record["operation"] == "scan_stream" {
+			records = append(records, record)
+		}
+	}
+	if len(records) != 1 {
+		t.Fatalf("%d scan_stream records logged, want 1", len(records))
+	}
+	if records[0]["job_id"] != "streamlog" {
+		t.Errorf("record without job ID: %v", records[0])
+	}
+}
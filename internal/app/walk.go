@@ -0,0 +1,287 @@
+// internal/app/walk.go
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bradsec/gofindmyfonts/internal/logging"
+)
+
+// dirReader lists a directory, as os.ReadDir does
+type dirReader func(name string) ([]os.DirEntry, error)
+
+// workerPool bounds how much file system work a scan does at once: directory
+// reads, archive listings and hashing font files
+type workerPool chan struct{}
+
+// newWorkerPool returns a pool of n slots, at least one
+func newWorkerPool(n int) workerPool {
+	if n < 1 {
+		n = 1
+	}
+	return make(workerPool, n)
+}
+
+// acquire waits for a slot, reporting false if ctx is done first
+func (p workerPool) acquire(ctx context.Context) bool {
+	select {
+	case p <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (p workerPool) release() {
+	<-p
+}
+
+// walkOptions are the parts of a walk that are not scan policy
+type walkOptions struct {
+	readDir  dirReader  // Lists directories; os.ReadDir when nil
+	pool     workerPool // Shared with other scan work; policy.Workers slots when nil
+	archives *fontFiles // Lists archives and keeps their indexes; a new one when nil
+}
+
+// fontWalker visits the font files under a root according to a policy.
+// Directories are read concurrently, as many at a time as the pool allows,
+// and the font files of each directory are sent to a single collector.
+type fontWalker struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	root     string
+	policy   ScanPolicy
+	excludes excludeMatcher
+	readDir  dirReader
+	pool     workerPool
+	archives *fontFiles
+	files    chan []string // Font files of one directory
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	visited map[string]bool // Real paths of directories already walked
+	err     error           // First error that stops the walk
+}
+
+// walkFonts calls visit for each font file under root that the policy allows,
+// as soon as its directory has been read. visit is called from one goroutine at a time. The
+// walk reports whether it stopped early at policy.MaxFiles; which files are
+// collected before the limit depends on the order directories are read in.
+// Unreadable directories and broken symlinks are logged and skipped.
+func walkFonts(ctx context.Context, root string, policy ScanPolicy, opts walkOptions, visit func(path string)) (bool, error) {
+	excludes, err := compileExcludes(policy.Exclude)
+	if err != nil {
+		return false, err
+	}
+	if opts.readDir == nil {
+		opts.readDir = os.ReadDir
+	}
+	if opts.pool == nil {
+		opts.pool = newWorkerPool(policy.Workers)
+	}
+	if opts.archives == nil {
+		opts.archives = newFontFiles(DefaultMaxFileSize)
+		defer opts.archives.close()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := &fontWalker{
+		ctx:      ctx,
+		cancel:   cancel,
+		root:     root,
+		policy:   policy,
+		excludes: excludes,
+		readDir:  opts.readDir,
+		pool:     opts.pool,
+		archives: opts.archives,
+		files:    make(chan []string, cap(opts.pool)),
+		visited:  make(map[string]bool),
+	}
+
+	w.wg.Add(1)
+	go w.walkDir(root, 1)
+	go func() {
+		w.wg.Wait()
+		close(w.files)
+	}()
+
+	count := 0
+	truncated := false
+	for batch := range w.files {
+		for _, path := range batch {
+			if truncated {
+				break // Drain so the walkers can exit
+			}
+			if policy.MaxFiles > 0 && count >= policy.MaxFiles {
+				truncated = true
+				cancel()
+				logging.InfoContext(ctx, fmt.Sprintf("Stopped after %d font files", policy.MaxFiles), "walk_fonts", root)
+				break
+			}
+			count++
+			visit(path)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return false, w.err
+	}
+	if !truncated && ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	return truncated, nil
+}
+
+// fail records the first error and stops the walk
+func (w *fontWalker) fail(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+	w.cancel()
+}
+
+// firstVisit reports whether the real directory behind dir has not been walked
+// yet, so symlink loops and directories linked twice are walked once. Without
+// followed symlinks every directory is reached once, so nothing is tracked.
+func (w *fontWalker) firstVisit(dir string) bool {
+	if !w.policy.FollowSymlinks {
+		return true
+	}
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.visited[real] {
+		return false
+	}
+	w.visited[real] = true
+	return true
+}
+
+func (w *fontWalker) walkDir(dir string, depth int) {
+	defer w.wg.Done()
+
+	if !w.firstVisit(dir) {
+		logging.DebugContext(w.ctx, "Skipping directory already scanned", "walk_fonts", dir)
+		return
+	}
+
+	if !w.pool.acquire(w.ctx) {
+		return
+	}
+	entries, err := w.readDir(dir)
+	w.pool.release()
+
+	if err != nil {
+		if dir != w.root && (os.IsPermission(err) || os.IsNotExist(err)) {
+			logging.WarnContext(w.ctx, "Skipping unreadable directory", "walk_fonts", dir, err)
+			return
+		}
+		w.fail(&FontProcessError{Op: "access", Path: dir, Err: err})
+		return
+	}
+
+	var found []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !w.policy.IncludeHidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			info, err := os.Stat(path)
+			if err != nil {
+				logging.WarnContext(w.ctx, "Skipping broken symlink", "walk_fonts", path, err)
+				continue
+			}
+			isDir = info.IsDir()
+			if isDir && !w.policy.FollowSymlinks {
+				continue
+			}
+		}
+
+		if len(w.excludes) > 0 {
+			rel, err := filepath.Rel(w.root, path)
+			if err != nil {
+				rel = name
+			}
+			if w.excludes.excluded(filepath.ToSlash(rel), isDir) {
+				continue
+			}
+		}
+
+		if isDir {
+			if w.policy.MaxDepth > 0 && depth >= w.policy.MaxDepth {
+				continue
+			}
+			w.wg.Add(1)
+			go w.walkDir(path, depth+1)
+			continue
+		}
+
+		ext := strings.ToLower(filepath.Ext(name))
+		switch {
+		case allowedExts[ext]:
+			found = append(found, path)
+		case ext == ".zip" && w.policy.Archives:
+			if !w.pool.acquire(w.ctx) {
+				return
+			}
+			members, err := w.archives.listArchive(path, w.policy.IncludeHidden)
+			w.pool.release()
+			if err != nil {
+				logging.WarnContext(w.ctx, "Skipping unreadable archive", "walk_fonts", path, err)
+				continue
+			}
+			found = append(found, members...)
+		}
+	}
+
+	if len(found) > 0 {
+		select {
+		case w.files <- found:
+		case <-w.ctx.Done():
+		}
+	}
+}
+
+// walkFontsSerial is a single-threaded recursive walk, equivalent to the
+// filepath.Walk scan the concurrent walker replaced. It only applies the
+// hidden-file rule and is kept so the bench command can compare the two.
+func walkFontsSerial(dir string, policy ScanPolicy, readDir dirReader, visit func(path string)) error {
+	entries, err := readDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !policy.IncludeHidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if entry.IsDir() {
+			if err := walkFontsSerial(path, policy, readDir, visit); err != nil {
+				logging.Warn("Skipping unreadable directory", "walk_fonts", path, err)
+			}
+			continue
+		}
+		if allowedExts[strings.ToLower(filepath.Ext(name))] {
+			visit(path)
+		}
+	}
+	return nil
+}
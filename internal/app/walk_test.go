@@ -0,0 +1,53 @@
+// internal/app/walk_test.go
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// BenchmarkWalk compares the serial walk with the concurrent walker on a
+// synthetic tree, with a delay on every directory read as on a network share
+func BenchmarkWalk(b *testing.B) {
+	root := b.TempDir()
+	files, err := CreateSyntheticTree(root, 3, 6, 8)
+	if err != nil {
+		b.Fatal(err)
+	}
+	slowReadDir := func(name string) ([]os.DirEntry, error) {
+		time.Sleep(time.Millisecond)
+		return os.ReadDir(name)
+	}
+
+	count := func(b *testing.B, found int) {
+		if found != files {
+			b.Fatalf("found %d files, want %d", found, files)
+		}
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			found := 0
+			if err := walkFontsSerial(root, ScanPolicy{}, slowReadDir, func(string) { found++ }); err != nil {
+				b.Fatal(err)
+			}
+			count(b, found)
+		}
+	})
+	for _, workers := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("concurrent-%d", workers), func(b *testing.B) {
+			policy := ScanPolicy{Workers: workers}
+			for i := 0; i < b.N; i++ {
+				found := 0
+				_, err := walkFonts(context.Background(), root, policy, walkOptions{readDir: slowReadDir}, func(string) { found++ })
+				if err != nil {
+					b.Fatal(err)
+				}
+				count(b, found)
+			}
+		})
+	}
+}
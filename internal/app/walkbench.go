@@ -0,0 +1,135 @@
+// internal/app/walkbench.go
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WalkBenchOptions describes a comparison of the serial and concurrent walkers
+type WalkBenchOptions struct {
+	Dir     string        // Tree to walk; a synthetic tree is created when empty
+	Depth   int           // Synthetic tree: directory levels below the root
+	Fanout  int           // Synthetic tree: subdirectories in each directory
+	Files   int           // Synthetic tree: font files in each directory
+	Latency time.Duration // Added to every directory read, to simulate a network share
+	Workers []int         // Worker counts to time the concurrent walker with
+	Runs    int           // Runs of each walker; the fastest is reported
+}
+
+// WalkBenchResult is the fastest run of one walker
+type WalkBenchResult struct {
+	Walker   string        `json:"walker"`
+	Workers  int           `json:"workers"`
+	Files    int           `json:"files"`
+	Duration time.Duration `json:"duration"`
+}
+
+// BenchWalk times the serial walk and the concurrent walker with each worker
+// count over the same tree. It fails if the walkers find different numbers of
+// font files.
+func BenchWalk(opts WalkBenchOptions) ([]WalkBenchResult, error) {
+	root := opts.Dir
+	if root == "" {
+		dir, err := os.MkdirTemp("", "fontwalk-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		if _, err := CreateSyntheticTree(dir, opts.Depth, opts.Fanout, opts.Files); err != nil {
+			return nil, err
+		}
+		root = dir
+	}
+	if opts.Runs < 1 {
+		opts.Runs = 1
+	}
+
+	readDir := dirReader(os.ReadDir)
+	if opts.Latency > 0 {
+		readDir = func(name string) ([]os.DirEntry, error) {
+			time.Sleep(opts.Latency)
+			return os.ReadDir(name)
+		}
+	}
+
+	// fastest runs walk opts.Runs times and returns the file count and shortest time
+	fastest := func(walk func(visit func(string)) error) (int, time.Duration, error) {
+		var best time.Duration
+		files := 0
+		for i := 0; i < opts.Runs; i++ {
+			files = 0
+			started := time.Now()
+			if err := walk(func(string) { files++ }); err != nil {
+				return 0, 0, err
+			}
+			if elapsed := time.Since(started); i == 0 || elapsed < best {
+				best = elapsed
+			}
+		}
+		return files, best, nil
+	}
+
+	policy := ScanPolicy{}
+	files, elapsed, err := fastest(func(visit func(string)) error {
+		return walkFontsSerial(root, policy, readDir, visit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := []WalkBenchResult{{Walker: "serial", Workers: 1, Files: files, Duration: elapsed}}
+
+	for _, workers := range opts.Workers {
+		policy.Workers = workers
+		found, elapsed, err := fastest(func(visit func(string)) error {
+			_, err := walkFonts(context.Background(), root, policy, walkOptions{readDir: readDir}, visit)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if found != files {
+			return nil, fmt.Errorf("concurrent walker with %d workers found %d files, serial walk found %d", workers, found, files)
+		}
+		results = append(results, WalkBenchResult{Walker: "concurrent", Workers: workers, Files: found, Duration: elapsed})
+	}
+	return results, nil
+}
+
+// CreateSyntheticTree fills root with depth levels of directories, fanout
+// subdirectories each, and files empty font files plus one other file in every
+// directory. It returns the number of font files created.
+func CreateSyntheticTree(root string, depth, fanout, files int) (int, error) {
+	exts := []string{".ttf", ".otf", ".woff", ".woff2"}
+	created := 0
+	var fill func(dir string, level int) error
+	fill = func(dir string, level int) error {
+		for i := 0; i < files; i++ {
+			name := fmt.Sprintf("Font%d-%d%s", level, i, exts[i%len(exts)])
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+				return err
+			}
+			created++
+		}
+		if err := os.WriteFile(filepath.Join(dir, "README.txt"), nil, 0644); err != nil {
+			return err
+		}
+		if level == depth {
+			return nil
+		}
+		for i := 0; i < fanout; i++ {
+			sub := filepath.Join(dir, fmt.Sprintf("dir%d", i))
+			if err := os.Mkdir(sub, 0755); err != nil {
+				return err
+			}
+			if err := fill(sub, level+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return created, fill(root, 0)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 
 	"github.com/bradsec/gofindmyfonts/internal/logging"
 )
@@ -47,30 +48,31 @@ var browsers = []BrowserInfo{
 
 // OpenBrowser attempts to open the provided URL in a browser
 func OpenBrowser(url string) error {
-	logging.Info(fmt.Sprintf("Attempting to open URL in browser: %s", url), "open_browser", "")
+	// The query may hold the access token, which does not belong in the log
+	logging.Info(fmt.Sprintf("Attempting to open URL in browser: %s", strings.SplitN(url, "?", 2)[0]), "open_browser", "")
 
 	var err error
 	switch runtime.GOOS {
 	case "darwin":
 		// On macOS, try 'open' command first
-		logging.Info("Trying macOS 'open' command", "open_browser", "")
+		logging.Debug("Trying macOS 'open' command", "open_browser", "")
 		err = exec.Command("open", url).Start()
 		if err != nil {
-			logging.Error("Failed to use 'open' command", "open_browser", "", err)
+			logging.Warn("Failed to use 'open' command", "open_browser", "", err)
 			return tryAlternativeBrowsers(url)
 		}
 	case "windows":
-		logging.Info("Trying Windows 'start' command", "open_browser", "")
+		logging.Debug("Trying Windows 'start' command", "open_browser", "")
 		err = exec.Command("cmd", "/c", "start", url).Start()
 		if err != nil {
-			logging.Error("Failed to use 'start' command", "open_browser", "", err)
+			logging.Warn("Failed to use 'start' command", "open_browser", "", err)
 			return tryAlternativeBrowsers(url)
 		}
 	case "linux":
-		logging.Info("Trying Linux 'xdg-open' command", "open_browser", "")
+		logging.Debug("Trying Linux 'xdg-open' command", "open_browser", "")
 		err = exec.Command("xdg-open", url).Start()
 		if err != nil {
-			logging.Error("Failed to use 'xdg-open' command", "open_browser", "", err)
+			logging.Warn("Failed to use 'xdg-open' command", "open_browser", "", err)
 			return tryAlternativeBrowsers(url)
 		}
 	default:
@@ -84,7 +86,7 @@ func OpenBrowser(url string) error {
 
 // tryAlternativeBrowsers attempts to open the URL using installed browsers
 func tryAlternativeBrowsers(url string) error {
-	logging.Info("Attempting to find alternative browsers", "try_browser", "")
+	logging.Debug("Attempting to find alternative browsers", "try_browser", "")
 
 	for _, browser := range browsers {
 		path := ""
@@ -110,16 +112,16 @@ func tryAlternativeBrowsers(url string) error {
 		}
 
 		if path != "" {
-			logging.Info(fmt.Sprintf("Trying browser: %s", browser.Name), "try_browser", path)
+			logging.Debug(fmt.Sprintf("Trying browser: %s", browser.Name), "try_browser", path)
 			if _, err := os.Stat(path); err == nil {
 				if err := exec.Command(path, url).Start(); err != nil {
-					logging.Error(fmt.Sprintf("Failed to start %s", browser.Name), "try_browser", path, err)
+					logging.Warn(fmt.Sprintf("Failed to start %s", browser.Name), "try_browser", path, err)
 				} else {
 					logging.Info(fmt.Sprintf("Successfully opened URL with %s", browser.Name), "try_browser", path)
 					return nil
 				}
 			} else {
-				logging.Info(fmt.Sprintf("Browser not found: %s", browser.Name), "try_browser", path)
+				logging.Debug(fmt.Sprintf("Browser not found: %s", browser.Name), "try_browser", path)
 			}
 		}
 	}
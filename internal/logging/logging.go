@@ -2,80 +2,287 @@
 package logging
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
-	"path/filepath"
 	"runtime"
-	"time"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type LogLevel string
 
 const (
+	LogLevelDebug LogLevel = "DEBUG"
 	LogLevelInfo  LogLevel = "INFO"
+	LogLevelWarn  LogLevel = "WARN"
 	LogLevelError LogLevel = "ERROR"
 )
 
-type LogEntry struct {
-	Time      time.Time `json:"time"`
-	Level     LogLevel  `json:"level"`
-	Message   string    `json:"message"`
-	OS        string    `json:"os"`
-	Operation string    `json:"operation,omitempty"`
-	Path      string    `json:"path,omitempty"`
-	Error     string    `json:"error,omitempty"`
+// Level returns the slog level; unknown levels are treated as INFO
+func (l LogLevel) Level() slog.Level {
+	switch l {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
 }
 
-var logger *log.Logger
+// ParseLevel parses "debug", "info", "warn" (or "warning") and "error" in any case
+func ParseLevel(s string) (LogLevel, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "DEBUG":
+		return LogLevelDebug, nil
+	case "INFO":
+		return LogLevelInfo, nil
+	case "WARN", "WARNING":
+		return LogLevelWarn, nil
+	case "ERROR":
+		return LogLevelError, nil
+	}
+	return "", fmt.Errorf("unknown log level %q (use debug, info, warn or error)", s)
+}
 
-func InitLogger(logDir string) error {
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory: %v", err)
+// Log formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// ParseFormat parses "json" or "text" in any case
+func ParseFormat(s string) (string, error) {
+	switch format := strings.ToLower(strings.TrimSpace(s)); format {
+	case FormatJSON, FormatText:
+		return format, nil
 	}
+	return "", fmt.Errorf("unknown log format %q (use json or text)", s)
+}
+
+// Options configures the log file and console output
+type Options struct {
+	Dir           string   // Directory for the log files
+	FileLevel     LogLevel // Least severe level written to the log file
+	ConsoleLevel  LogLevel // Least severe level printed to stderr
+	FileFormat    string   // FormatJSON or FormatText; JSON if empty
+	ConsoleFormat string   // FormatJSON or FormatText; text if empty
+	MaxSize       int64    // Bytes written to a file before starting the next; 0 rotates only by date
+	MaxFiles      int      // Log files kept, including the current one; 0 keeps all
+}
 
-	logFile := filepath.Join(logDir, fmt.Sprintf("gofindmyfonts-%s.log", time.Now().Format("2006-01-02")))
-	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+var (
+	mu     sync.Mutex // Guards output
+	output *rotatingFile
+	logger atomic.Pointer[slog.Logger]
+)
+
+func init() {
+	logger.Store(slog.New(multiHandler{newHandler(FormatText, os.Stderr, slog.LevelInfo), ringHandler{}}))
+}
+
+// Logger returns the process-wide logger. Until Init is called it prints
+// records of INFO and above to stderr. ERROR records are also kept for
+// RecentErrors.
+func Logger() *slog.Logger {
+	return logger.Load()
+}
+
+// Init opens the log file for today in opts.Dir and replaces the process-wide
+// logger with one writing to the file and the console at their own levels
+func Init(opts Options) error {
+	file, err := openRotatingFile(opts.Dir, opts.MaxSize, opts.MaxFiles)
 	if err != nil {
-		return fmt.Errorf("failed to open log file: %v", err)
+		return err
+	}
+
+	mu.Lock()
+	if output != nil {
+		output.Close()
 	}
+	output = file
+	mu.Unlock()
 
-	logger = log.New(file, "", 0)
+	if opts.FileFormat == "" {
+		opts.FileFormat = FormatJSON
+	}
+	if opts.ConsoleFormat == "" {
+		opts.ConsoleFormat = FormatText
+	}
+	fileHandler := newHandler(opts.FileFormat, fileWriter{}, opts.FileLevel.Level()).
+		WithAttrs([]slog.Attr{slog.String("os", runtime.GOOS)})
+	consoleHandler := newHandler(opts.ConsoleFormat, os.Stderr, opts.ConsoleLevel.Level())
+	logger.Store(slog.New(multiHandler{fileHandler, consoleHandler, ringHandler{}}))
 	return nil
 }
 
-func logMessage(level LogLevel, msg string, op string, path string, err error) {
-	entry := LogEntry{
-		Time:      time.Now(),
-		Level:     level,
-		Message:   msg,
-		OS:        runtime.GOOS,
-		Operation: op,
-		Path:      path,
+// Close flushes and closes the log file. Later records only go to the console.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if output == nil {
+		return nil
 	}
+	file := output
+	output = nil
+	return file.Close()
+}
 
-	if err != nil {
-		entry.Error = err.Error()
+// fileWriter writes to the current log file, discarding output once it is closed
+type fileWriter struct{}
+
+func (fileWriter) Write(p []byte) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if output == nil {
+		return len(p), nil
 	}
+	return output.Write(p)
+}
 
-	jsonEntry, err := json.Marshal(entry)
-	if err != nil {
-		log.Printf("Error marshaling log entry: %v", err)
-		return
+// newHandler returns a JSON or text handler. The message is written under
+// "message", as in the log files written before slog.
+func newHandler(format string, w io.Writer, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.MessageKey {
+				a.Key = "message"
+			}
+			return a
+		},
+	}
+	if format == FormatText {
+		return slog.NewTextHandler(w, opts)
 	}
+	return slog.NewJSONHandler(w, opts)
+}
+
+// multiHandler sends each record to every handler that accepts its level
+type multiHandler []slog.Handler
+
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
 
-	if logger != nil {
-		logger.Println(string(jsonEntry))
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range m {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
 	}
-	// Also print to stdout
-	log.Println(string(jsonEntry))
+	return nil
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
+
+// contextKey is the context key of a request- or job-scoped logger
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the process-wide logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return Logger()
+}
+
+// With returns a copy of ctx whose logger adds args, such as a request or job
+// ID, to every record
+func With(ctx context.Context, args ...any) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(args...))
+}
+
+// attrs returns the operation, path and error of a shim call as slog attributes
+func attrs(op string, path string, err error) []any {
+	var args []any
+	if op != "" {
+		args = append(args, slog.String("operation", op))
+	}
+	if path != "" {
+		args = append(args, slog.String("path", path))
+	}
+	if err != nil {
+		args = append(args, slog.String("error", err.Error()))
+	}
+	return args
+}
+
+// Debug, Info, Warn and Error log to the process-wide logger with an
+// operation name and path. Code with a request or job context should use the
+// Context forms, or FromContext(ctx) for other attributes, so records carry
+// its IDs.
+
+// Debug logs detail that is only useful when tracing a problem, such as per-file progress
+func Debug(msg string, op string, path string) {
+	Logger().Debug(msg, attrs(op, path, nil)...)
 }
 
 func Info(msg string, op string, path string) {
-	logMessage(LogLevelInfo, msg, op, path, nil)
+	Logger().Info(msg, attrs(op, path, nil)...)
+}
+
+// Warn logs a problem the server recovered from, such as a skipped file
+func Warn(msg string, op string, path string, err error) {
+	Logger().Warn(msg, attrs(op, path, err)...)
 }
 
 func Error(msg string, op string, path string, err error) {
-	logMessage(LogLevelError, msg, op, path, err)
+	Logger().Error(msg, attrs(op, path, err)...)
+}
+
+// DebugContext is Debug with the logger carried by ctx
+func DebugContext(ctx context.Context, msg string, op string, path string) {
+	FromContext(ctx).DebugContext(ctx, msg, attrs(op, path, nil)...)
+}
+
+// InfoContext is Info with the logger carried by ctx
+func InfoContext(ctx context.Context, msg string, op string, path string) {
+	FromContext(ctx).InfoContext(ctx, msg, attrs(op, path, nil)...)
+}
+
+// WarnContext is Warn with the logger carried by ctx
+func WarnContext(ctx context.Context, msg string, op string, path string, err error) {
+	FromContext(ctx).WarnContext(ctx, msg, attrs(op, path, err)...)
+}
+
+// ErrorContext is Error with the logger carried by ctx
+func ErrorContext(ctx context.Context, msg string, op string, path string, err error) {
+	FromContext(ctx).ErrorContext(ctx, msg, attrs(op, path, err)...)
 }
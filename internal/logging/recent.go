@@ -0,0 +1,153 @@
+// internal/logging/recent.go
+package logging
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// recentSize is how many ERROR records are kept in memory for diagnostics
+const recentSize = 100
+
+// Entry is a log record kept for diagnostics
+type Entry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// entryRing holds the most recent entries, overwriting the oldest
+type entryRing struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+var recentErrors = &entryRing{entries: make([]Entry, recentSize)}
+
+func (r *entryRing) add(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the entries, oldest first
+func (r *entryRing) list() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]Entry(nil), r.entries[:r.next]...)
+	}
+	return append(append([]Entry(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// RecentErrors returns up to the last 100 ERROR records logged by this process, oldest first
+func RecentErrors() []Entry {
+	return recentErrors.list()
+}
+
+// ringHandler keeps ERROR records in recentErrors, whatever the file and
+// console levels are
+type ringHandler struct {
+	attrs  []slog.Attr
+	prefix string // Group names joined with dots
+}
+
+func (h ringHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelError
+}
+
+func (h ringHandler) Handle(_ context.Context, r slog.Record) error {
+	entry := Entry{Time: r.Time, Level: r.Level.String(), Message: r.Message, Attrs: make(map[string]string)}
+	for _, a := range h.attrs {
+		entry.Attrs[a.Key] = a.Value.String()
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		entry.Attrs[h.prefix+a.Key] = a.Value.String()
+		return true
+	})
+	recentErrors.add(entry)
+	return nil
+}
+
+func (h ringHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	combined := append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		combined = append(combined, slog.Attr{Key: h.prefix + a.Key, Value: a.Value})
+	}
+	return ringHandler{attrs: combined, prefix: h.prefix}
+}
+
+func (h ringHandler) WithGroup(name string) slog.Handler {
+	return ringHandler{attrs: h.attrs, prefix: h.prefix + name + "."}
+}
+
+// ErrorsFromFiles returns the last n ERROR records in the JSON log files in
+// dir, oldest first. It lets a separate process, such as the doctor command,
+// report what a server logged.
+func ErrorsFromFiles(dir string, n int) ([]Entry, error) {
+	files, err := listLogFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Entry
+	for i := len(files) - 1; i >= 0 && len(found) < n; i-- {
+		entries, err := readErrors(filepath.Join(dir, files[i].name))
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > n-len(found) {
+			entries = entries[len(entries)-(n-len(found)):]
+		}
+		found = append(entries, found...)
+	}
+	return found, nil
+}
+
+// readErrors returns the ERROR records of one log file; lines that are not
+// JSON records, as written with the text format, are skipped
+func readErrors(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record map[string]any
+		if json.Unmarshal(scanner.Bytes(), &record) != nil || record["level"] != "ERROR" {
+			continue
+		}
+		entry := Entry{Level: "ERROR", Attrs: make(map[string]string)}
+		for key, value := range record {
+			switch key {
+			case "time":
+				entry.Time, _ = time.Parse(time.RFC3339Nano, fmt.Sprint(value))
+			case "message":
+				entry.Message = fmt.Sprint(value)
+			case "level", "os":
+			default:
+				entry.Attrs[key] = fmt.Sprint(value)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,188 @@
+// internal/logging/rotate.go
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	logFilePrefix = "gofindmyfonts-"
+	logFileSuffix = ".log"
+	logDateFormat = "2006-01-02"
+)
+
+// logFileName names the log file for a date and sequence number:
+// gofindmyfonts-2006-01-02.log, then gofindmyfonts-2006-01-02.1.log and so on
+// once a file reaches the size limit
+func logFileName(date string, seq int) string {
+	if seq == 0 {
+		return logFilePrefix + date + logFileSuffix
+	}
+	return fmt.Sprintf("%s%s.%d%s", logFilePrefix, date, seq, logFileSuffix)
+}
+
+// parseLogFileName returns the date and sequence number of a log file name
+func parseLogFileName(name string) (string, int, bool) {
+	if !strings.HasPrefix(name, logFilePrefix) || !strings.HasSuffix(name, logFileSuffix) {
+		return "", 0, false
+	}
+	date, seqText, hasSeq := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(name, logFilePrefix), logFileSuffix), ".")
+	if _, err := time.Parse(logDateFormat, date); err != nil {
+		return "", 0, false
+	}
+	if !hasSeq {
+		return date, 0, true
+	}
+	seq, err := strconv.Atoi(seqText)
+	if err != nil || seq < 1 {
+		return "", 0, false
+	}
+	return date, seq, true
+}
+
+// logFile is a log file found in the log directory
+type logFile struct {
+	name string
+	date string
+	seq  int
+}
+
+// listLogFiles returns the log files in dir, oldest first
+func listLogFiles(dir string) ([]logFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []logFile
+	for _, entry := range entries {
+		if date, seq, ok := parseLogFileName(entry.Name()); ok && !entry.IsDir() {
+			files = append(files, logFile{name: entry.Name(), date: date, seq: seq})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].date != files[j].date {
+			return files[i].date < files[j].date
+		}
+		return files[i].seq < files[j].seq
+	})
+	return files, nil
+}
+
+// rotatingFile writes to the log file for the current date, moving to a new
+// file when the date changes or the file reaches maxSize, and deleting the
+// oldest files beyond maxFiles. It is not safe for concurrent use.
+type rotatingFile struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+	now      func() time.Time // Clock that dates the log files
+
+	file *os.File
+	date string
+	seq  int
+	size int64
+}
+
+// openRotatingFile continues the latest log file for today in dir
+func openRotatingFile(dir string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %v", err)
+	}
+	r := &rotatingFile{dir: dir, maxSize: maxSize, maxFiles: maxFiles, now: time.Now}
+	if err := r.open(r.now().Format(logDateFormat)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the latest file for date, or the next one if it is full
+func (r *rotatingFile) open(date string) error {
+	seq := 0
+	files, err := listLogFiles(r.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list log files: %v", err)
+	}
+	for _, file := range files {
+		if file.date == date && file.seq > seq {
+			seq = file.seq
+		}
+	}
+	if info, err := os.Stat(filepath.Join(r.dir, logFileName(date, seq))); err == nil && r.maxSize > 0 && info.Size() >= r.maxSize {
+		seq++
+	}
+	return r.openFile(date, seq)
+}
+
+// openFile switches to the log file for date and seq and removes old files
+func (r *rotatingFile) openFile(date string, seq int) error {
+	path := filepath.Join(r.dir, logFileName(date, seq))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+
+	if r.file != nil {
+		r.file.Close()
+	}
+	r.file = file
+	r.date = date
+	r.seq = seq
+	r.size = info.Size()
+	r.prune()
+	return nil
+}
+
+// prune deletes the oldest log files beyond maxFiles
+func (r *rotatingFile) prune() {
+	if r.maxFiles <= 0 {
+		return
+	}
+	files, err := listLogFiles(r.dir)
+	if err != nil {
+		return
+	}
+	current := logFileName(r.date, r.seq)
+	for len(files) > r.maxFiles {
+		if files[0].name != current {
+			os.Remove(filepath.Join(r.dir, files[0].name))
+		}
+		files = files[1:]
+	}
+}
+
+// Write appends p to the current log file, rotating first if needed. If the
+// next file cannot be opened, writing continues in the current one.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if date := r.now().Format(logDateFormat); date != r.date {
+		if err := r.open(date); err != nil {
+			fmt.Fprintf(os.Stderr, "Log rotation failed: %v\n", err)
+		}
+	} else if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.openFile(r.date, r.seq+1); err != nil {
+			fmt.Fprintf(os.Stderr, "Log rotation failed: %v\n", err)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close flushes and closes the current log file
+func (r *rotatingFile) Close() error {
+	if err := r.file.Sync(); err != nil {
+		r.file.Close()
+		return fmt.Errorf("failed to flush log file: %v", err)
+	}
+	return r.file.Close()
+}
@@ -0,0 +1,162 @@
+// internal/logging/rotate_test.go
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testClock is a clock the test moves by hand
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+// openTestFile opens a rotating file in dir as openRotatingFile does, dated by clock
+func openTestFile(t *testing.T, dir string, maxSize int64, maxFiles int, clock *testClock) *rotatingFile {
+	t.Helper()
+	r := &rotatingFile{dir: dir, maxSize: maxSize, maxFiles: maxFiles, now: clock.Now}
+	if err := r.open(r.now().Format(logDateFormat)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+// logFiles returns the names of the files in dir and their contents
+func logFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
+
+func write(t *testing.T, r *rotatingFile, line string) {
+	t.Helper()
+	if _, err := r.Write([]byte(line)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	clock := &testClock{now: time.Date(2026, 3, 14, 9, 0, 0, 0, time.Local)}
+	r := openTestFile(t, dir, 10, 0, clock)
+
+	write(t, r, "one 1234\n")
+	write(t, r, "two 1234\n")
+	write(t, r, "a line longer than the limit\n") // Starts a file, and is not split
+	write(t, r, "four\n")
+
+	want := map[string]string{
+		"gofindmyfonts-2026-03-14.log":   "one 1234\n",
+		"gofindmyfonts-2026-03-14.1.log": "two 1234\n",
+		"gofindmyfonts-2026-03-14.2.log": "a line longer than the limit\n",
+		"gofindmyfonts-2026-03-14.3.log": "four\n",
+	}
+	if got := logFiles(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("files %v, want %v", got, want)
+	}
+
+	// A restart continues the latest file, or starts the next if it is full
+	r.Close()
+	r = openTestFile(t, dir, 10, 0, clock)
+	write(t, r, "five\n")
+	r.Close()
+	r = openTestFile(t, dir, 10, 0, clock)
+	write(t, r, "six\n")
+	got := logFiles(t, dir)
+	if got["gofindmyfonts-2026-03-14.3.log"] != "four\nfive\n" || got["gofindmyfonts-2026-03-14.4.log"] != "six\n" {
+		t.Errorf("after restarts: %v", got)
+	}
+}
+
+func TestRotateByDate(t *testing.T) {
+	dir := t.TempDir()
+	clock := &testClock{now: time.Date(2026, 3, 14, 23, 59, 0, 0, time.Local)}
+	r := openTestFile(t, dir, 1024, 0, clock)
+
+	write(t, r, "before midnight\n")
+	clock.now = clock.now.Add(2 * time.Minute)
+	write(t, r, "after midnight\n")
+	clock.now = clock.now.Add(time.Hour)
+	write(t, r, "same day\n")
+
+	want := map[string]string{
+		"gofindmyfonts-2026-03-14.log": "before midnight\n",
+		"gofindmyfonts-2026-03-15.log": "after midnight\nsame day\n",
+	}
+	if got := logFiles(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("files %v, want %v", got, want)
+	}
+}
+
+func TestPruneOldestFiles(t *testing.T) {
+	dir := t.TempDir()
+	// Sequence numbers sort numerically: .2 is older than .10
+	for _, name := range []string{
+		"gofindmyfonts-2026-03-01.log",
+		"gofindmyfonts-2026-03-02.log",
+		"gofindmyfonts-2026-03-02.2.log",
+		"gofindmyfonts-2026-03-02.10.log",
+		"notes.txt",
+		"gofindmyfonts-latest.log",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clock := &testClock{now: time.Date(2026, 3, 3, 12, 0, 0, 0, time.Local)}
+	r := openTestFile(t, dir, 10, 3, clock)
+	assertFiles := func(want ...string) {
+		t.Helper()
+		var got []string
+		for name := range logFiles(t, dir) {
+			got = append(got, name)
+		}
+		sort.Strings(got)
+		want = append(want, "gofindmyfonts-latest.log", "notes.txt")
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("files %v, want %v", got, want)
+		}
+	}
+	assertFiles("gofindmyfonts-2026-03-02.2.log", "gofindmyfonts-2026-03-02.10.log", "gofindmyfonts-2026-03-03.log")
+
+	// Rotating by size and by date prunes too
+	write(t, r, "one 1234\n")
+	write(t, r, "two 1234\n")
+	assertFiles("gofindmyfonts-2026-03-02.10.log", "gofindmyfonts-2026-03-03.log", "gofindmyfonts-2026-03-03.1.log")
+	clock.now = clock.now.AddDate(0, 0, 2)
+	write(t, r, "three\n")
+	assertFiles("gofindmyfonts-2026-03-03.log", "gofindmyfonts-2026-03-03.1.log", "gofindmyfonts-2026-03-05.log")
+}
+
+func TestKeepAllFiles(t *testing.T) {
+	dir := t.TempDir()
+	clock := &testClock{now: time.Date(2026, 3, 14, 9, 0, 0, 0, time.Local)}
+	r := openTestFile(t, dir, 0, 0, clock)
+	for day := 0; day < 5; day++ {
+		write(t, r, "line\n")
+		clock.now = clock.now.AddDate(0, 0, 1)
+	}
+	if got := len(logFiles(t, dir)); got != 5 {
+		t.Errorf("%d files kept, want 5", got)
+	}
+}
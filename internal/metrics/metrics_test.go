@@ -0,0 +1,77 @@
+// internal/metrics/metrics_test.go
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	saved := Default
+	Default = NewRegistry()
+	defer func() { Default = saved }()
+
+	scans := NewCounter("test_scans_total", "Scans run.\nCounted per outcome, with \\ escaped.", "outcome", "dir")
+	NewCounter("test_idle_total", "Counter without labels.")
+	duration := NewHistogram("test_duration_seconds", "Scan duration.", []float64{1, 0.5, 2.5}, "kind")
+	NewHistogram("test_empty_bytes", "Histogram without labels.", []float64{10})
+
+	scans.Inc("success", `C:\Fonts`)
+	scans.Add(2, "failure", "say \"hi\"\nbye")
+	scans.Inc("success", `C:\Fonts`)
+	duration.Observe(0.25, "woff2")
+	duration.Observe(1, "woff2")
+	duration.Observe(7, "woff2")
+	duration.Observe(0.75, "ttf")
+
+	var out strings.Builder
+	if err := WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP test_scans_total Scans run.\nCounted per outcome, with \\ escaped.
+# TYPE test_scans_total counter
+test_scans_total{outcome="failure",dir="say \"hi\"\nbye"} 2
+test_scans_total{outcome="success",dir="C:\\Fonts"} 2
+# HELP test_idle_total Counter without labels.
+# TYPE test_idle_total counter
+test_idle_total 0
+# HELP test_duration_seconds Scan duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{kind="ttf",le="0.5"} 0
+test_duration_seconds_bucket{kind="ttf",le="1"} 1
+test_duration_seconds_bucket{kind="ttf",le="2.5"} 1
+test_duration_seconds_bucket{kind="ttf",le="+Inf"} 1
+test_duration_seconds_sum{kind="ttf"} 0.75
+test_duration_seconds_count{kind="ttf"} 1
+test_duration_seconds_bucket{kind="woff2",le="0.5"} 1
+test_duration_seconds_bucket{kind="woff2",le="1"} 2
+test_duration_seconds_bucket{kind="woff2",le="2.5"} 2
+test_duration_seconds_bucket{kind="woff2",le="+Inf"} 3
+test_duration_seconds_sum{kind="woff2"} 8.25
+test_duration_seconds_count{kind="woff2"} 3
+# HELP test_empty_bytes Histogram without labels.
+# TYPE test_empty_bytes histogram
+test_empty_bytes_bucket{le="10"} 0
+test_empty_bytes_bucket{le="+Inf"} 0
+test_empty_bytes_sum 0
+test_empty_bytes_count 0
+`
+	if got := out.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDuplicateMetricPanics(t *testing.T) {
+	saved := Default
+	Default = NewRegistry()
+	defer func() { Default = saved }()
+
+	NewCounter("test_total", "First.")
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate name did not panic")
+		}
+	}()
+	NewHistogram("test_total", "Second.", ExponentialBuckets(1, 2, 3))
+}
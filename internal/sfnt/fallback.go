@@ -0,0 +1,85 @@
+// internal/sfnt/fallback.go
+package sfnt
+
+import (
+	"fmt"
+	"math"
+)
+
+// Fallback describes @font-face overrides that make a local fallback font take up
+// the same space as a web font, to reduce layout shift while the web font loads
+type Fallback struct {
+	Family         string            `json:"family"`
+	FallbackFamily string            `json:"fallbackFamily"`
+	LocalNames     []string          `json:"localNames"`
+	Overrides      FontFaceOverrides `json:"overrides"`
+	CSS            string            `json:"css"`
+}
+
+// MatchFallback computes size-adjust and metric overrides for fallback so that
+// it matches the average glyph width and vertical metrics of web. If family is
+// empty, the web font's own family name is used.
+func MatchFallback(web, fallback *Font, family string) (*Fallback, error) {
+	webMetrics, err := web.Metrics()
+	if err != nil {
+		return nil, fmt.Errorf("web font: %w", err)
+	}
+	fallbackMetrics, err := fallback.Metrics()
+	if err != nil {
+		return nil, fmt.Errorf("fallback font: %w", err)
+	}
+
+	webWidth, err := web.AverageWidth()
+	if err != nil {
+		return nil, fmt.Errorf("web font: %w", err)
+	}
+	fallbackWidth, err := fallback.AverageWidth()
+	if err != nil {
+		return nil, fmt.Errorf("fallback font: %w", err)
+	}
+
+	// Scale the fallback so its average glyph is as wide as the web font's
+	sizeAdjust := (webWidth / float64(webMetrics.UnitsPerEm)) /
+		(fallbackWidth / float64(fallbackMetrics.UnitsPerEm))
+	if math.IsNaN(sizeAdjust) || math.IsInf(sizeAdjust, 0) || sizeAdjust <= 0 {
+		return nil, fmt.Errorf("unable to compute size-adjust")
+	}
+
+	// Overrides are relative to the adjusted size, so divide by sizeAdjust
+	target := webMetrics.overrides()
+	overrides := FontFaceOverrides{
+		SizeAdjust:      round(sizeAdjust*100, 2),
+		AscentOverride:  round(target.AscentOverride/sizeAdjust, 2),
+		DescentOverride: round(target.DescentOverride/sizeAdjust, 2),
+		LineGapOverride: round(target.LineGapOverride/sizeAdjust, 2),
+	}
+
+	if family == "" {
+		family = web.FamilyName()
+	}
+	var localNames []string
+	for _, id := range []uint16{NameFull, NamePostScript} {
+		if name := fallback.Name(id); name != "" && (len(localNames) == 0 || localNames[0] != name) {
+			localNames = append(localNames, name)
+		}
+	}
+	if len(localNames) == 0 {
+		return nil, fmt.Errorf("fallback font has no full or PostScript name for local()")
+	}
+
+	src := ""
+	for i, name := range localNames {
+		if i > 0 {
+			src += ", "
+		}
+		src += "local(" + cssString(name) + ")"
+	}
+
+	return &Fallback{
+		Family:         family,
+		FallbackFamily: fallback.FamilyName(),
+		LocalNames:     localNames,
+		Overrides:      overrides,
+		CSS:            overrides.CSS(family+" Fallback", src),
+	}, nil
+}
@@ -0,0 +1,228 @@
+// internal/sfnt/glyphs.go
+package sfnt
+
+import (
+	"fmt"
+	"sort"
+)
+
+// latinFrequencies are approximate relative frequencies of characters in English
+// text, used to weight glyph widths the same way body copy would
+var latinFrequencies = map[rune]float64{
+	' ': 0.1530, 'e': 0.0951, 't': 0.0703, 'a': 0.0613, 'o': 0.0588,
+	'i': 0.0546, 'n': 0.0542, 's': 0.0499, 'r': 0.0472, 'h': 0.0447,
+	'l': 0.0312, 'd': 0.0306, 'c': 0.0222, 'u': 0.0210, 'm': 0.0186,
+	'f': 0.0164, 'p': 0.0154, 'g': 0.0146, 'w': 0.0140, 'y': 0.0131,
+	'b': 0.0116, ',': 0.0101, '.': 0.0094, 'v': 0.0082, 'k': 0.0050,
+	'T': 0.0039, 'I': 0.0033, 'A': 0.0031, 'S': 0.0026, 'C': 0.0023,
+	'x': 0.0018, 'M': 0.0016, 'j': 0.0012, 'q': 0.0008, 'z': 0.0007,
+}
+
+// cmapSubtable is a parsed character-to-glyph mapping
+type cmapSubtable struct {
+	data   reader
+	format uint16
+}
+
+// GlyphIndex returns the glyph ID mapped to r, or 0 if the font has no glyph for it
+func (f *Font) GlyphIndex(r rune) (uint16, error) {
+	sub, err := f.cmap()
+	if err != nil {
+		return 0, err
+	}
+	return sub.lookup(r), nil
+}
+
+// AdvanceWidth returns the horizontal advance of a glyph in font units
+func (f *Font) AdvanceWidth(glyph uint16) (int, error) {
+	hhea := reader(f.Table("hhea"))
+	hmtx := reader(f.Table("hmtx"))
+	if hhea == nil || hmtx == nil {
+		return 0, fmt.Errorf("%w: hhea/hmtx", ErrMissingTable)
+	}
+
+	numberOfHMetrics, ok := hhea.u16(34)
+	if !ok || numberOfHMetrics == 0 {
+		return 0, fmt.Errorf("invalid hhea table")
+	}
+
+	// Glyphs past numberOfHMetrics share the last advance width
+	index := int(glyph)
+	if index >= int(numberOfHMetrics) {
+		index = int(numberOfHMetrics) - 1
+	}
+	advance, ok := hmtx.u16(index * 4)
+	if !ok {
+		return 0, fmt.Errorf("invalid hmtx table")
+	}
+	return int(advance), nil
+}
+
+// AverageWidth returns the frequency-weighted average advance width of English
+// text in font units. Characters the font does not map are skipped.
+func (f *Font) AverageWidth() (float64, error) {
+	sub, err := f.cmap()
+	if err != nil {
+		return 0, err
+	}
+
+	var total, weight float64
+	for r, frequency := range latinFrequencies {
+		glyph := sub.lookup(r)
+		if glyph == 0 {
+			continue
+		}
+		advance, err := f.AdvanceWidth(glyph)
+		if err != nil {
+			return 0, err
+		}
+		total += float64(advance) * frequency
+		weight += frequency
+	}
+
+	if weight == 0 {
+		return 0, fmt.Errorf("font has no glyphs for latin text")
+	}
+	return total / weight, nil
+}
+
+// cmap selects the best Unicode subtable from the cmap table
+func (f *Font) cmap() (*cmapSubtable, error) {
+	cmap := reader(f.Table("cmap"))
+	if cmap == nil {
+		return nil, fmt.Errorf("%w: cmap", ErrMissingTable)
+	}
+
+	numTables, ok := cmap.u16(2)
+	if !ok {
+		return nil, fmt.Errorf("invalid cmap table")
+	}
+
+	type candidate struct {
+		priority int
+		sub      *cmapSubtable
+	}
+	var candidates []candidate
+	for i := 0; i < int(numTables); i++ {
+		platform, _ := cmap.u16(4 + i*8)
+		encoding, _ := cmap.u16(6 + i*8)
+		offset, ok := cmap.u32(8 + i*8)
+		if !ok || int(offset) >= len(cmap) {
+			continue
+		}
+		format, ok := cmap.u16(int(offset))
+		if !ok || (format != 4 && format != 12) {
+			continue
+		}
+
+		// Prefer full Unicode (format 12) over BMP-only subtables
+		priority := -1
+		switch {
+		case platform == 3 && encoding == 10, platform == 0 && (encoding == 4 || encoding == 6):
+			priority = 3
+		case platform == 3 && encoding == 1, platform == 0:
+			priority = 2
+		case platform == 3 && encoding == 0:
+			priority = 1
+		}
+		if priority < 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			priority: priority,
+			sub:      &cmapSubtable{data: cmap[offset:], format: format},
+		})
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no supported unicode cmap subtable")
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].priority > candidates[j].priority
+	})
+	return candidates[0].sub, nil
+}
+
+// lookup maps a rune to a glyph ID, returning 0 when unmapped
+func (c *cmapSubtable) lookup(r rune) uint16 {
+	switch c.format {
+	case 4:
+		return c.lookupFormat4(r)
+	case 12:
+		return c.lookupFormat12(r)
+	}
+	return 0
+}
+
+// lookupFormat4 handles segment mapping to delta values (BMP only)
+func (c *cmapSubtable) lookupFormat4(r rune) uint16 {
+	if r > 0xFFFF {
+		return 0
+	}
+	code := uint16(r)
+
+	segCountX2, ok := c.data.u16(6)
+	if !ok {
+		return 0
+	}
+	segCount := int(segCountX2 / 2)
+	endCodes := 14
+	startCodes := endCodes + segCount*2 + 2
+	idDeltas := startCodes + segCount*2
+	idRangeOffsets := idDeltas + segCount*2
+
+	for i := 0; i < segCount; i++ {
+		end, ok := c.data.u16(endCodes + i*2)
+		if !ok {
+			return 0
+		}
+		if code > end {
+			continue
+		}
+		start, _ := c.data.u16(startCodes + i*2)
+		if code < start {
+			return 0
+		}
+		delta, _ := c.data.u16(idDeltas + i*2)
+		rangeOffset, _ := c.data.u16(idRangeOffsets + i*2)
+		if rangeOffset == 0 {
+			return code + delta
+		}
+		glyphOffset := idRangeOffsets + i*2 + int(rangeOffset) + int(code-start)*2
+		glyph, ok := c.data.u16(glyphOffset)
+		if !ok || glyph == 0 {
+			return 0
+		}
+		return glyph + delta
+	}
+	return 0
+}
+
+// lookupFormat12 handles segmented coverage (full Unicode range)
+func (c *cmapSubtable) lookupFormat12(r rune) uint16 {
+	numGroups, ok := c.data.u32(12)
+	if !ok {
+		return 0
+	}
+	code := uint32(r)
+	low, high := 0, int(numGroups)-1
+	for low <= high {
+		mid := (low + high) / 2
+		group := 16 + mid*12
+		start, ok1 := c.data.u32(group)
+		end, ok2 := c.data.u32(group + 4)
+		startGlyph, ok3 := c.data.u32(group + 8)
+		if !ok1 || !ok2 || !ok3 {
+			return 0
+		}
+		switch {
+		case code < start:
+			high = mid - 1
+		case code > end:
+			low = mid + 1
+		default:
+			return uint16(startGlyph + code - start)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,130 @@
+// internal/sfnt/layout.go
+package sfnt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LayoutFeatures lists the OpenType layout features and script/language systems of a font
+type LayoutFeatures struct {
+	Features      []string            `json:"features"`                // Unique GSUB and GPOS feature tags
+	Scripts       map[string][]string `json:"scripts"`                 // Script tag -> language system tags
+	StylisticSets map[string]string   `json:"stylisticSets,omitempty"` // ssXX tag -> UI name from the name table
+}
+
+// HasFeature reports whether the font supports the feature tag
+func (l *LayoutFeatures) HasFeature(tag string) bool {
+	for _, feature := range l.Features {
+		if feature == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Features reads the feature and script lists of the GSUB and GPOS tables
+func (f *Font) Features() (*LayoutFeatures, error) {
+	result := &LayoutFeatures{
+		Features: []string{},
+		Scripts:  make(map[string][]string),
+	}
+
+	features := make(map[string]bool)
+	languages := make(map[string]map[string]bool)
+	for _, tag := range []string{"GSUB", "GPOS"} {
+		table := reader(f.Table(tag))
+		if table == nil {
+			continue
+		}
+		if err := f.readLayoutTable(table, features, languages, result); err != nil {
+			return nil, fmt.Errorf("%s: %w", tag, err)
+		}
+	}
+
+	for feature := range features {
+		result.Features = append(result.Features, feature)
+	}
+	sort.Strings(result.Features)
+
+	for script, langs := range languages {
+		list := make([]string, 0, len(langs))
+		for lang := range langs {
+			list = append(list, lang)
+		}
+		sort.Strings(list)
+		result.Scripts[script] = list
+	}
+	return result, nil
+}
+
+// readLayoutTable collects feature tags, script/language systems and stylistic set names
+func (f *Font) readLayoutTable(table reader, features map[string]bool, languages map[string]map[string]bool, result *LayoutFeatures) error {
+	scriptListOffset, ok1 := table.u16(4)
+	featureListOffset, ok2 := table.u16(6)
+	if !ok1 || !ok2 {
+		return fmt.Errorf("invalid header")
+	}
+
+	// Script list: each script has an optional default language system plus named ones
+	scriptList := int(scriptListOffset)
+	scriptCount, _ := table.u16(scriptList)
+	for i := 0; i < int(scriptCount); i++ {
+		record := scriptList + 2 + i*6
+		if record+6 > len(table) {
+			return fmt.Errorf("truncated script list")
+		}
+		script := strings.TrimSpace(string(table[record : record+4]))
+		offset, _ := table.u16(record + 4)
+		scriptTable := scriptList + int(offset)
+
+		if languages[script] == nil {
+			languages[script] = make(map[string]bool)
+		}
+		if defaultLangSys, ok := table.u16(scriptTable); ok && defaultLangSys != 0 {
+			languages[script]["dflt"] = true
+		}
+		langSysCount, _ := table.u16(scriptTable + 2)
+		for j := 0; j < int(langSysCount); j++ {
+			langRecord := scriptTable + 4 + j*6
+			if langRecord+6 > len(table) {
+				return fmt.Errorf("truncated script table")
+			}
+			languages[script][strings.TrimSpace(string(table[langRecord:langRecord+4]))] = true
+		}
+	}
+
+	// Feature list: stylistic sets may carry a UI name ID in their feature parameters
+	featureList := int(featureListOffset)
+	featureCount, _ := table.u16(featureList)
+	for i := 0; i < int(featureCount); i++ {
+		record := featureList + 2 + i*6
+		if record+6 > len(table) {
+			return fmt.Errorf("truncated feature list")
+		}
+		tag := string(table[record : record+4])
+		features[tag] = true
+
+		if !strings.HasPrefix(tag, "ss") {
+			continue
+		}
+		offset, _ := table.u16(record + 4)
+		featureTable := featureList + int(offset)
+		paramsOffset, ok := table.u16(featureTable)
+		if !ok || paramsOffset == 0 {
+			continue
+		}
+		nameID, ok := table.u16(featureTable + int(paramsOffset) + 2)
+		if !ok {
+			continue
+		}
+		if name := f.Name(nameID); name != "" {
+			if result.StylisticSets == nil {
+				result.StylisticSets = make(map[string]string)
+			}
+			result.StylisticSets[tag] = name
+		}
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+// internal/sfnt/metrics.go
+package sfnt
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// fsSelection bit 7: use the OS/2 typo metrics for line spacing (OS/2 version 4+)
+const fsSelectionUseTypoMetrics = 1 << 7
+
+// lineHeightTolerance is the relative difference in line height tolerated between metric sets
+const lineHeightTolerance = 0.01
+
+// Metrics holds the vertical metrics of a font in font units. Ascender, Descender
+// and LineGap come from the hhea table, which is what most platforms use by default.
+type Metrics struct {
+	UnitsPerEm     int  `json:"unitsPerEm"`
+	YMin           int  `json:"yMin"`
+	YMax           int  `json:"yMax"`
+	Ascender       int  `json:"ascender"`
+	Descender      int  `json:"descender"`
+	LineGap        int  `json:"lineGap"`
+	OS2Version     int  `json:"os2Version"`
+	TypoAscender   int  `json:"typoAscender"`
+	TypoDescender  int  `json:"typoDescender"`
+	TypoLineGap    int  `json:"typoLineGap"`
+	WinAscent      int  `json:"winAscent"`
+	WinDescent     int  `json:"winDescent"`
+	UseTypoMetrics bool `json:"useTypoMetrics"`
+	XHeight        int  `json:"xHeight,omitempty"`
+	CapHeight      int  `json:"capHeight,omitempty"`
+	HasOS2         bool `json:"hasOS2"`
+}
+
+// MetricsReport describes inconsistencies between the hhea, typo and win metric
+// sets, and suggests @font-face overrides that pin the line box across platforms
+type MetricsReport struct {
+	Issues      []string           `json:"issues"`
+	LineHeights map[string]float64 `json:"lineHeights"` // Line height in em for each metric set
+	Overrides   FontFaceOverrides  `json:"overrides"`
+	CSS         string             `json:"css"`
+}
+
+// FontFaceOverrides holds @font-face metric override descriptors as percentages
+type FontFaceOverrides struct {
+	SizeAdjust      float64 `json:"sizeAdjust"`
+	AscentOverride  float64 `json:"ascentOverride"`
+	DescentOverride float64 `json:"descentOverride"`
+	LineGapOverride float64 `json:"lineGapOverride"`
+}
+
+// Metrics reads the vertical metrics from the head, hhea and OS/2 tables
+func (f *Font) Metrics() (*Metrics, error) {
+	head := reader(f.Table("head"))
+	if head == nil {
+		return nil, fmt.Errorf("%w: head", ErrMissingTable)
+	}
+	unitsPerEm, ok := head.u16(18)
+	if !ok || unitsPerEm == 0 {
+		return nil, fmt.Errorf("invalid head table")
+	}
+	yMin, _ := head.i16(38)
+	yMax, _ := head.i16(42)
+
+	hhea := reader(f.Table("hhea"))
+	if hhea == nil {
+		return nil, fmt.Errorf("%w: hhea", ErrMissingTable)
+	}
+	ascender, ok1 := hhea.i16(4)
+	descender, ok2 := hhea.i16(6)
+	lineGap, ok3 := hhea.i16(8)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, fmt.Errorf("invalid hhea table")
+	}
+
+	metrics := &Metrics{
+		UnitsPerEm: int(unitsPerEm),
+		YMin:       int(yMin),
+		YMax:       int(yMax),
+		Ascender:   int(ascender),
+		Descender:  int(descender),
+		LineGap:    int(lineGap),
+	}
+
+	os2 := reader(f.Table("OS/2"))
+	version, ok := os2.u16(0)
+	if !ok {
+		return metrics, nil
+	}
+	fsSelection, _ := os2.u16(62)
+	typoAscender, ok1 := os2.i16(68)
+	typoDescender, ok2 := os2.i16(70)
+	typoLineGap, ok3 := os2.i16(72)
+	winAscent, ok4 := os2.u16(74)
+	winDescent, ok5 := os2.u16(76)
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 {
+		return metrics, nil
+	}
+
+	metrics.HasOS2 = true
+	metrics.OS2Version = int(version)
+	metrics.TypoAscender = int(typoAscender)
+	metrics.TypoDescender = int(typoDescender)
+	metrics.TypoLineGap = int(typoLineGap)
+	metrics.WinAscent = int(winAscent)
+	metrics.WinDescent = int(winDescent)
+	metrics.UseTypoMetrics = version >= 4 && fsSelection&fsSelectionUseTypoMetrics != 0
+
+	// sxHeight and sCapHeight are only present from OS/2 version 2
+	if version >= 2 {
+		if xHeight, ok := os2.i16(86); ok {
+			metrics.XHeight = int(xHeight)
+		}
+		if capHeight, ok := os2.i16(88); ok {
+			metrics.CapHeight = int(capHeight)
+		}
+	}
+
+	return metrics, nil
+}
+
+// Report checks the metric sets for the mismatches that make fonts render with
+// different line heights or clipped glyphs across browsers and platforms
+func (m *Metrics) Report(family string) *MetricsReport {
+	upm := float64(m.UnitsPerEm)
+	report := &MetricsReport{
+		Issues:      []string{},
+		LineHeights: make(map[string]float64),
+	}
+	addIssue := func(format string, args ...interface{}) {
+		report.Issues = append(report.Issues, fmt.Sprintf(format, args...))
+	}
+
+	if m.UnitsPerEm < 16 || m.UnitsPerEm > 16384 {
+		addIssue("unitsPerEm %d is outside the valid range 16-16384", m.UnitsPerEm)
+	}
+	if m.Descender > 0 {
+		addIssue("hhea descender %d should be zero or negative", m.Descender)
+	}
+
+	hhea := float64(m.Ascender-m.Descender+m.LineGap) / upm
+	report.LineHeights["hhea"] = round(hhea, 4)
+
+	if !m.HasOS2 {
+		addIssue("font has no OS/2 table; Windows line spacing is unpredictable")
+	} else {
+		typo := float64(m.TypoAscender-m.TypoDescender+m.TypoLineGap) / upm
+		win := float64(m.WinAscent+m.WinDescent) / upm
+		report.LineHeights["typo"] = round(typo, 4)
+		report.LineHeights["win"] = round(win, 4)
+
+		if m.TypoDescender > 0 {
+			addIssue("OS/2 sTypoDescender %d should be zero or negative", m.TypoDescender)
+		}
+		if m.UseTypoMetrics {
+			if differs(hhea, typo) {
+				addIssue("USE_TYPO_METRICS is set but hhea line height (%.3fem) differs from typo line height (%.3fem)", hhea, typo)
+			}
+		} else {
+			if differs(hhea, win) {
+				addIssue("hhea line height (%.3fem) differs from win line height (%.3fem); macOS and Windows will space lines differently", hhea, win)
+			}
+			if differs(typo, win) {
+				if m.OS2Version < 4 {
+					addIssue("typo and win metrics differ and OS/2 version %d cannot set USE_TYPO_METRICS", m.OS2Version)
+				} else {
+					addIssue("typo and win metrics differ but USE_TYPO_METRICS is not set")
+				}
+			}
+		}
+		if m.Ascender != m.TypoAscender || m.Descender != m.TypoDescender {
+			addIssue("hhea ascender/descender (%d/%d) do not match typo ascender/descender (%d/%d)",
+				m.Ascender, m.Descender, m.TypoAscender, m.TypoDescender)
+		}
+		if m.YMax > m.WinAscent || -m.YMin > m.WinDescent {
+			addIssue("win metrics (%d/%d) do not cover the glyph bounding box (%d/%d); glyphs may clip on Windows",
+				m.WinAscent, m.WinDescent, m.YMax, m.YMin)
+		}
+		if m.OS2Version < 2 {
+			addIssue("OS/2 version %d does not record x-height or cap height", m.OS2Version)
+		}
+	}
+
+	report.Overrides = m.overrides()
+	report.CSS = report.Overrides.CSS(family, "")
+	return report
+}
+
+// overrides returns the metrics browsers should use, expressed as @font-face
+// override percentages, so every platform lays out the same line box
+func (m *Metrics) overrides() FontFaceOverrides {
+	ascender, descender, lineGap := m.Ascender, m.Descender, m.LineGap
+	if m.UseTypoMetrics {
+		ascender, descender, lineGap = m.TypoAscender, m.TypoDescender, m.TypoLineGap
+	}
+
+	upm := float64(m.UnitsPerEm)
+	return FontFaceOverrides{
+		SizeAdjust:      100,
+		AscentOverride:  round(float64(ascender)/upm*100, 2),
+		DescentOverride: round(math.Abs(float64(descender))/upm*100, 2),
+		LineGapOverride: round(float64(lineGap)/upm*100, 2),
+	}
+}
+
+// CSS renders the overrides as an @font-face rule. A src of "" is emitted as a placeholder.
+func (o FontFaceOverrides) CSS(family, src string) string {
+	if src == "" {
+		src = `url("...")`
+	}
+
+	var b strings.Builder
+	b.WriteString("@font-face {\n")
+	fmt.Fprintf(&b, "  font-family: %s;\n", cssString(family))
+	fmt.Fprintf(&b, "  src: %s;\n", src)
+	fmt.Fprintf(&b, "  size-adjust: %s%%;\n", formatPercent(o.SizeAdjust))
+	fmt.Fprintf(&b, "  ascent-override: %s%%;\n", formatPercent(o.AscentOverride))
+	fmt.Fprintf(&b, "  descent-override: %s%%;\n", formatPercent(o.DescentOverride))
+	fmt.Fprintf(&b, "  line-gap-override: %s%%;\n", formatPercent(o.LineGapOverride))
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// cssString quotes s as a CSS string, escaping it as CSSOM serializes strings:
+// quotes and backslashes are backslash-escaped, control characters such as
+// newline become hex escapes ("\a "), and NUL becomes U+FFFD. Go's %q is not
+// used because its escapes, such as \n and \u00e9, mean something else in CSS.
+func cssString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == 0:
+			b.WriteRune('\uFFFD')
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\%x ", r)
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func differs(a, b float64) bool {
+	if a == 0 && b == 0 {
+		return false
+	}
+	return math.Abs(a-b)/math.Max(math.Abs(a), math.Abs(b)) > lineHeightTolerance
+}
+
+func round(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
+
+func formatPercent(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
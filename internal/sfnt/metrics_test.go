@@ -0,0 +1,250 @@
+// internal/sfnt/metrics_test.go
+package sfnt
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// buildFont assembles a TrueType font from raw tables
+func buildFont(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	data := make([]byte, 12+16*len(tags))
+	binary.BigEndian.PutUint32(data, 0x00010000)
+	binary.BigEndian.PutUint16(data[4:], uint16(len(tags)))
+	for i, tag := range tags {
+		record := data[12+16*i:]
+		copy(record, tag)
+		binary.BigEndian.PutUint32(record[8:], uint32(len(data)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(tables[tag])))
+		data = append(data, tables[tag]...)
+	}
+	return data
+}
+
+// table writes big-endian 16-bit fields at the given offsets into a table of size bytes
+func table(size int, fields map[int]int) []byte {
+	data := make([]byte, size)
+	for offset, value := range fields {
+		binary.BigEndian.PutUint16(data[offset:], uint16(int16(value)))
+	}
+	return data
+}
+
+func headTable(unitsPerEm, yMin, yMax int) []byte {
+	return table(54, map[int]int{18: unitsPerEm, 38: yMin, 42: yMax})
+}
+
+func hheaTable(ascender, descender, lineGap int) []byte {
+	return table(36, map[int]int{4: ascender, 6: descender, 8: lineGap})
+}
+
+// os2Table is a version 4 OS/2 table; fields override its defaults
+func os2Table(fields map[int]int) []byte {
+	values := map[int]int{
+		0:  4,    // version
+		62: 0x80, // fsSelection: USE_TYPO_METRICS
+		68: 800,  // sTypoAscender
+		70: -200, // sTypoDescender
+		72: 0,    // sTypoLineGap
+		74: 900,  // usWinAscent
+		76: 250,  // usWinDescent
+		86: 500,  // sxHeight
+		88: 700,  // sCapHeight
+	}
+	for offset, value := range fields {
+		values[offset] = value
+	}
+	return table(96, values)
+}
+
+// fixtureTables are the tables of a font with consistent metrics
+func fixtureTables() map[string][]byte {
+	return map[string][]byte{
+		"head": headTable(1000, -240, 880),
+		"hhea": hheaTable(800, -200, 0),
+		"OS/2": os2Table(nil),
+	}
+}
+
+func parseTables(t *testing.T, tables map[string][]byte) *Font {
+	t.Helper()
+	font, err := Parse(buildFont(tables))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return font
+}
+
+func TestMetrics(t *testing.T) {
+	metrics, err := parseTables(t, fixtureTables()).Metrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Metrics{
+		UnitsPerEm: 1000, YMin: -240, YMax: 880,
+		Ascender: 800, Descender: -200, LineGap: 0,
+		OS2Version: 4, TypoAscender: 800, TypoDescender: -200, TypoLineGap: 0,
+		WinAscent: 900, WinDescent: 250, UseTypoMetrics: true,
+		XHeight: 500, CapHeight: 700, HasOS2: true,
+	}
+	if !reflect.DeepEqual(metrics, want) {
+		t.Errorf("got %+v\nwant %+v", metrics, want)
+	}
+
+	report := metrics.Report("Fixture")
+	if len(report.Issues) != 0 {
+		t.Errorf("consistent metrics reported %q", report.Issues)
+	}
+	if want := (FontFaceOverrides{SizeAdjust: 100, AscentOverride: 80, DescentOverride: 20}); report.Overrides != want {
+		t.Errorf("overrides %+v, want %+v", report.Overrides, want)
+	}
+	if want := map[string]float64{"hhea": 1, "typo": 1, "win": 1.15}; !reflect.DeepEqual(report.LineHeights, want) {
+		t.Errorf("line heights %v, want %v", report.LineHeights, want)
+	}
+}
+
+func TestMetricsOS2Versions(t *testing.T) {
+	tests := []struct {
+		name           string
+		os2            []byte
+		hasOS2         bool
+		useTypoMetrics bool
+		xHeight        int
+	}{
+		{"version 4", os2Table(nil), true, true, 500},
+		{"version 3 ignores USE_TYPO_METRICS", os2Table(map[int]int{0: 3}), true, false, 500},
+		{"version 1 has no x-height", os2Table(map[int]int{0: 1})[:78], true, false, 0},
+		{"version 2 truncated before x-height", os2Table(map[int]int{0: 2})[:80], true, false, 0},
+		{"truncated before win metrics", os2Table(nil)[:76], false, false, 0},
+		{"empty", []byte{}, false, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tables := fixtureTables()
+			tables["OS/2"] = tt.os2
+			metrics, err := parseTables(t, tables).Metrics()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if metrics.HasOS2 != tt.hasOS2 || metrics.UseTypoMetrics != tt.useTypoMetrics || metrics.XHeight != tt.xHeight {
+				t.Errorf("hasOS2 %v, useTypoMetrics %v, xHeight %d; want %v, %v, %d",
+					metrics.HasOS2, metrics.UseTypoMetrics, metrics.XHeight, tt.hasOS2, tt.useTypoMetrics, tt.xHeight)
+			}
+			if metrics.Ascender != 800 || metrics.UnitsPerEm != 1000 {
+				t.Errorf("hhea and head metrics lost: %+v", metrics)
+			}
+		})
+	}
+
+	// Without OS/2 the hhea metrics are still read, and the table is reported missing
+	tables := fixtureTables()
+	delete(tables, "OS/2")
+	metrics, err := parseTables(t, tables).Metrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := metrics.Report("Fixture"); metrics.HasOS2 || !strings.Contains(strings.Join(report.Issues, "\n"), "no OS/2 table") {
+		t.Errorf("font without OS/2 reported %q", report.Issues)
+	}
+}
+
+func TestMetricsInvalidTables(t *testing.T) {
+	tests := []struct {
+		name    string
+		tag     string
+		table   []byte // nil removes the table
+		missing bool
+	}{
+		{"no head", "head", nil, true},
+		{"no hhea", "hhea", nil, true},
+		{"head truncated before unitsPerEm", "head", headTable(1000, 0, 0)[:19], false},
+		{"head with zero unitsPerEm", "head", headTable(0, 0, 0), false},
+		{"hhea truncated before lineGap", "hhea", hheaTable(800, -200, 0)[:9], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tables := fixtureTables()
+			if tt.table == nil {
+				delete(tables, tt.tag)
+			} else {
+				tables[tt.tag] = tt.table
+			}
+			_, err := parseTables(t, tables).Metrics()
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if errors.Is(err, ErrMissingTable) != tt.missing {
+				t.Errorf("error %v, missing table %v", err, tt.missing)
+			}
+		})
+	}
+}
+
+func TestParseTruncatedFont(t *testing.T) {
+	data := buildFont(fixtureTables())
+	for _, size := range []int{0, 11, 20, len(data) - 1} {
+		if _, err := Parse(data[:size]); err == nil {
+			t.Errorf("font truncated to %d bytes parsed", size)
+		}
+	}
+}
+
+func TestMetricsReportIssues(t *testing.T) {
+	tables := fixtureTables()
+	tables["hhea"] = hheaTable(900, -250, 0)
+	tables["OS/2"] = os2Table(map[int]int{0: 3, 62: 0, 74: 850, 76: 200})
+	metrics, err := parseTables(t, tables).Metrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues := strings.Join(metrics.Report("Fixture").Issues, "\n")
+	for _, want := range []string{
+		"hhea line height (1.150em) differs from win line height (1.050em)",
+		"typo and win metrics differ and OS/2 version 3 cannot set USE_TYPO_METRICS",
+		"hhea ascender/descender (900/-250) do not match typo ascender/descender (800/-200)",
+		"win metrics (850/200) do not cover the glyph bounding box (880/-240)",
+	} {
+		if !strings.Contains(issues, want) {
+			t.Errorf("issues do not include %q:\n%s", want, issues)
+		}
+	}
+}
+
+func TestCSSString(t *testing.T) {
+	tests := map[string]string{
+		"Inter":                  `"Inter"`,
+		`Say "Hi"`:               `"Say \"Hi\""`,
+		`C:\Fonts`:               `"C:\\Fonts"`,
+		"Two\nLines":             `"Two\a Lines"`,
+		"Tab\there\r\x7f":        `"Tab\9 here\d \7f "`,
+		"Nul\x00":                "\"Nul\uFFFD\"",
+		"Café ✓":                 `"Café ✓"`,
+		`"; } body { color: red`: `"\"; } body { color: red"`,
+	}
+	for in, want := range tests {
+		if got := cssString(in); got != want {
+			t.Errorf("cssString(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestOverridesCSSEscapesFamily(t *testing.T) {
+	css := FontFaceOverrides{SizeAdjust: 100}.CSS("Evil\"\n} body {", "")
+	if !strings.Contains(css, `font-family: "Evil\"\a } body {";`) {
+		t.Errorf("family not escaped:\n%s", css)
+	}
+	if lines := strings.Split(strings.TrimSuffix(css, "\n"), "\n"); len(lines) != 8 || lines[7] != "}" {
+		t.Errorf("family broke out of the rule:\n%s", css)
+	}
+}
@@ -0,0 +1,86 @@
+// internal/sfnt/names.go
+package sfnt
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// Common name table IDs
+const (
+	NameFamily               = 1
+	NameSubfamily            = 2
+	NameFull                 = 4
+	NamePostScript           = 6
+	NameTypographicFamily    = 16
+	NameTypographicSubfamily = 17
+)
+
+// Name returns the name table string with the given ID, preferring English
+// Windows Unicode records over Macintosh Roman ones. It returns "" if absent.
+func (f *Font) Name(id uint16) string {
+	table := reader(f.Table("name"))
+	count, ok1 := table.u16(2)
+	storage, ok2 := table.u16(4)
+	if !ok1 || !ok2 {
+		return ""
+	}
+
+	best, bestPriority := "", -1
+	for i := 0; i < int(count); i++ {
+		record := 6 + i*12
+		platform, _ := table.u16(record)
+		encoding, _ := table.u16(record + 2)
+		language, _ := table.u16(record + 4)
+		nameID, _ := table.u16(record + 6)
+		length, _ := table.u16(record + 8)
+		offset, ok := table.u16(record + 10)
+		if !ok || nameID != id {
+			continue
+		}
+
+		start := int(storage) + int(offset)
+		end := start + int(length)
+		if end > len(table) {
+			continue
+		}
+		raw := table[start:end]
+
+		var value string
+		priority := 0
+		switch {
+		case platform == 3 && (encoding == 1 || encoding == 10), platform == 0:
+			value = decodeUTF16(raw)
+			priority = 2
+			if platform == 3 && language == 0x0409 {
+				priority = 3
+			}
+		case platform == 1 && encoding == 0:
+			value = string(raw)
+			priority = 1
+		default:
+			continue
+		}
+
+		if priority > bestPriority && strings.TrimSpace(value) != "" {
+			best, bestPriority = value, priority
+		}
+	}
+	return strings.TrimSpace(best)
+}
+
+// FamilyName returns the typographic family name, falling back to the legacy family name
+func (f *Font) FamilyName() string {
+	if name := f.Name(NameTypographicFamily); name != "" {
+		return name
+	}
+	return f.Name(NameFamily)
+}
+
+func decodeUTF16(raw []byte) string {
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
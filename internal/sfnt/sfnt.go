@@ -0,0 +1,167 @@
+// internal/sfnt/sfnt.go
+package sfnt
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	maxTables    = 512
+	maxTableSize = 64 * 1024 * 1024 // 64MB
+)
+
+var (
+	// ErrUnsupportedFormat is returned for font containers that cannot be parsed directly
+	ErrUnsupportedFormat = errors.New("unsupported font format")
+	// ErrMissingTable is returned when a required table is not present in the font
+	ErrMissingTable = errors.New("missing table")
+)
+
+// Font provides access to the tables of a TrueType/OpenType font
+type Font struct {
+	tables map[string][]byte
+}
+
+// ParseFile reads and parses the font file at path
+func ParseFile(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses TTF, OTF, TTC (first font only) and WOFF data. WOFF2 is not
+// supported because its tables are Brotli compressed and transformed.
+func Parse(data []byte) (*Font, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("font data too short")
+	}
+
+	switch string(data[:4]) {
+	case "\x00\x01\x00\x00", "OTTO", "true":
+		return parseSFNT(data, 0)
+	case "ttcf":
+		if len(data) < 16 || binary.BigEndian.Uint32(data[8:12]) == 0 {
+			return nil, fmt.Errorf("invalid font collection header")
+		}
+		return parseSFNT(data, int(binary.BigEndian.Uint32(data[12:16])))
+	case "wOFF":
+		return parseWOFF(data)
+	case "wOF2":
+		return nil, fmt.Errorf("%w: woff2", ErrUnsupportedFormat)
+	default:
+		return nil, fmt.Errorf("%w: unknown signature %q", ErrUnsupportedFormat, data[:4])
+	}
+}
+
+// parseSFNT reads the table directory of an sfnt font starting at offset
+func parseSFNT(data []byte, offset int) (*Font, error) {
+	if offset < 0 || offset+12 > len(data) {
+		return nil, fmt.Errorf("invalid offset table")
+	}
+
+	numTables := int(binary.BigEndian.Uint16(data[offset+4:]))
+	if numTables > maxTables {
+		return nil, fmt.Errorf("too many tables: %d", numTables)
+	}
+
+	font := &Font{tables: make(map[string][]byte, numTables)}
+	dir := offset + 12
+	for i := 0; i < numTables; i++ {
+		record := dir + i*16
+		if record+16 > len(data) {
+			return nil, fmt.Errorf("truncated table directory")
+		}
+		tag := string(data[record : record+4])
+		start := int(binary.BigEndian.Uint32(data[record+8:]))
+		length := int(binary.BigEndian.Uint32(data[record+12:]))
+		if start < 0 || length < 0 || start+length > len(data) {
+			return nil, fmt.Errorf("table %q out of bounds", tag)
+		}
+		font.tables[tag] = data[start : start+length]
+	}
+	return font, nil
+}
+
+// parseWOFF reads and decompresses the tables of a WOFF 1.0 font
+func parseWOFF(data []byte) (*Font, error) {
+	if len(data) < 44 {
+		return nil, fmt.Errorf("truncated woff header")
+	}
+
+	numTables := int(binary.BigEndian.Uint16(data[12:]))
+	if numTables > maxTables {
+		return nil, fmt.Errorf("too many tables: %d", numTables)
+	}
+
+	font := &Font{tables: make(map[string][]byte, numTables)}
+	for i := 0; i < numTables; i++ {
+		record := 44 + i*20
+		if record+20 > len(data) {
+			return nil, fmt.Errorf("truncated table directory")
+		}
+		tag := string(data[record : record+4])
+		start := int(binary.BigEndian.Uint32(data[record+4:]))
+		compLength := int(binary.BigEndian.Uint32(data[record+8:]))
+		origLength := int(binary.BigEndian.Uint32(data[record+12:]))
+		if start < 0 || compLength < 0 || start+compLength > len(data) || origLength > maxTableSize {
+			return nil, fmt.Errorf("table %q out of bounds", tag)
+		}
+
+		table := data[start : start+compLength]
+		if compLength < origLength {
+			reader, err := zlib.NewReader(bytes.NewReader(table))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress table %q: %w", tag, err)
+			}
+			decompressed, err := io.ReadAll(io.LimitReader(reader, int64(origLength)))
+			reader.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress table %q: %w", tag, err)
+			}
+			table = decompressed
+		}
+		font.tables[tag] = table
+	}
+	return font, nil
+}
+
+// Table returns the raw data of the table with the given tag, or nil if absent
+func (f *Font) Table(tag string) []byte {
+	return f.tables[tag]
+}
+
+// HasTable reports whether the font contains the table with the given tag
+func (f *Font) HasTable(tag string) bool {
+	_, ok := f.tables[tag]
+	return ok
+}
+
+// reader provides bounds-checked big-endian reads from a table
+type reader []byte
+
+func (r reader) u16(offset int) (uint16, bool) {
+	if offset < 0 || offset+2 > len(r) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(r[offset:]), true
+}
+
+func (r reader) i16(offset int) (int16, bool) {
+	v, ok := r.u16(offset)
+	return int16(v), ok
+}
+
+func (r reader) u32(offset int) (uint32, bool) {
+	if offset < 0 || offset+4 > len(r) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(r[offset:]), true
+}
@@ -6,7 +6,8 @@ class VirtualFontList {
         this.visibleItems = new Set();
         this.options = {
             itemHeight: options.itemHeight || 300,
-            defaultFontSize: options.defaultFontSize || 24
+            defaultFontSize: options.defaultFontSize || 24,
+            jobId: options.jobId || ''
         };
         // Set initial CSS variable
         document.documentElement.style.setProperty('--preview-font-size', `${this.options.defaultFontSize}px`);
@@ -32,25 +33,62 @@ class VirtualFontList {
         const fragment = document.createDocumentFragment();
         
         this.fonts.forEach((font, index) => {
-            const div = document.createElement('div');
-            div.className = 'font-item';
-            div.dataset.index = index;
-            div.dataset.fontName = font.name.toLowerCase();
-            div.innerHTML = this.getPlaceholderContent(font);
-            fragment.appendChild(div);
+            fragment.appendChild(this.createItem(font, index));
         });
         
         this.container.appendChild(fragment);
     }
 
+    createItem(font, index) {
+        const div = document.createElement('div');
+        div.className = 'font-item';
+        div.dataset.index = index;
+        div.dataset.fontName = font.name.toLowerCase();
+        div.innerHTML = this.getPlaceholderContent(font);
+        return div;
+    }
+
+    // Append a font found by a streaming scan
+    addFont(font) {
+        const index = this.fonts.length;
+        this.fonts.push(font);
+        this.container.appendChild(this.createItem(font, index));
+        this.loadFontItem(index);
+        this.scheduleFilters();
+    }
+
+    // Replace a font after one of its conversions finished
+    updateFont(font) {
+        const index = this.fonts.findIndex(existing => existing.name === font.name);
+        if (index < 0) {
+            this.addFont(font);
+            return;
+        }
+        this.fonts[index] = font;
+        this.loadedFonts.delete(font.name);
+        this.visibleItems.delete(index);
+        this.loadFontItem(index);
+        this.scheduleFilters();
+    }
+
+    // Batch filter updates while fonts are streaming in
+    scheduleFilters() {
+        if (this.filterFrame) return;
+        this.filterFrame = requestAnimationFrame(() => {
+            this.filterFrame = null;
+            this.applyFilters();
+        });
+    }
+
     getPlaceholderContent(font) {
         return `
             <div class="font-header">
-                <h3>${font.name}</h3>
+                <h3>${escapeHtml(font.name)}</h3>
                 <div class="font-actions">
-                    ${this.generateFormatButtons(font.formats)}
+                    ${this.generateFormatButtons(font)}
                 </div>
             </div>
+            ${this.generateLibraryControls(font)}
             <div class="font-preview">
                 <div>Loading preview...</div>
             </div>`;
@@ -75,36 +113,176 @@ class VirtualFontList {
 
         const content = `
             <div class="font-header">
-                <h3>${font.name}</h3>
+                <h3>${escapeHtml(font.name)}</h3>
                 <div class="font-actions">
-                    ${this.generateFormatButtons(font.formats)}
+                    ${this.generateFormatButtons(font)}
                 </div>
             </div>
+            ${this.generateLibraryControls(font)}
             <div class="font-preview">
                 <style>
                     @font-face {
-                        font-family: "${font.name}";
-                        src: url("${font.preview}") format("woff2");
+                        font-family: ${cssString(font.name)};
+                        src: url(${cssString(font.preview)}) format("woff2");
                         font-display: swap;
                     }
                 </style>
-                <div style="font-family: '${font.name}';" class="preview-text">
-                    ${document.getElementById('sampleText').value}
+                <div style="font-family: ${escapeHtml(cssString(font.name))};" class="preview-text">
+                    ${escapeHtml(document.getElementById('sampleText').value)}
                 </div>
-            </div>`;
+            </div>
+            ${this.generateFeatureToggles(font)}
+            ${this.generateMetricsDetails(font)}`;
         
         this.loadedFonts.set(font.name, content);
         element.innerHTML = content;
         this.visibleItems.add(index);
     }
 
-    generateFormatButtons(formats) {
-        return Object.entries(formats)
+    generateLibraryControls(font) {
+        const favorite = fontLibrary.isFavorite(font.id);
+        const tags = fontLibrary.tags(font.id).join(', ');
+        return `
+            <div class="font-library">
+                <button type="button" class="favorite-button${favorite ? ' active' : ''}"
+                    data-font-id="${escapeHtml(font.id)}" title="Toggle favorite">${favorite ? '&#9733;' : '&#9734;'}</button>
+                <input type="text" class="tags-input" data-font-id="${escapeHtml(font.id)}"
+                    placeholder="Tags, comma separated" value="${escapeHtml(tags)}">
+                <label class="compare-toggle">
+                    <input type="checkbox" class="compare-checkbox" data-font-id="${escapeHtml(font.id)}"
+                        ${compareView.isSelected(font.id) ? 'checked' : ''}> Compare
+                </label>
+            </div>`;
+    }
+
+    generateFeatureToggles(font) {
+        const features = font.features;
+        if (!features || features.features.length === 0) return '';
+
+        const sets = features.stylisticSets || {};
+        const scripts = Object.keys(features.scripts).sort().join(', ');
+        return `
+            <details class="font-features">
+                <summary>OpenType features (${features.features.length})</summary>
+                <div class="feature-toggles">
+                    ${features.features.map(tag => `
+                        <button type="button" class="feature-toggle" data-feature="${escapeHtml(tag)}"
+                            title="${escapeHtml(sets[tag] || tag)}">${escapeHtml(sets[tag] ? `${tag}: ${sets[tag]}` : tag)}</button>
+                    `).join('')}
+                </div>
+                ${scripts ? `<div class="feature-scripts">Scripts: ${escapeHtml(scripts)}</div>` : ''}
+            </details>`;
+    }
+
+    // Toggle an OpenType feature on a card's preview via font-feature-settings
+    toggleFeature(button) {
+        button.classList.toggle('active');
+        const item = button.closest('.font-item');
+        const settings = Array.from(item.querySelectorAll('.feature-toggle.active'))
+            .map(toggle => `"${toggle.dataset.feature}" 1`)
+            .join(', ');
+        item.querySelector('.preview-text').style.fontFeatureSettings = settings || 'normal';
+    }
+
+    generateMetricsDetails(font) {
+        const m = font.metrics;
+        const report = font.metricsReport;
+        if (!m || !report) {
+            return '<div class="font-metrics unavailable">Metrics unavailable</div>';
+        }
+
+        const rows = [
+            ['unitsPerEm', m.unitsPerEm, '', ''],
+            ['hhea', m.ascender, m.descender, m.lineGap],
+            ['OS/2 typo', m.typoAscender, m.typoDescender, m.typoLineGap],
+            ['OS/2 win', m.winAscent, -m.winDescent, '']
+        ];
+        const issues = report.issues.length;
+        return `
+            <details class="font-metrics${issues ? ' has-issues' : ''}">
+                <summary>Metrics${issues ? ` &mdash; ${issues} issue${issues === 1 ? '' : 's'}` : ' &mdash; consistent'}</summary>
+                <table>
+                    <tr><th></th><th>Ascent</th><th>Descent</th><th>Line gap</th></tr>
+                    ${rows.map(row => `<tr>${row.map((cell, i) => i === 0 ? `<th>${cell}</th>` : `<td>${cell}</td>`).join('')}</tr>`).join('')}
+                </table>
+                <div>USE_TYPO_METRICS: ${m.useTypoMetrics ? 'on' : 'off'}
+                    &middot; x-height: ${m.xHeight || 'n/a'} &middot; cap height: ${m.capHeight || 'n/a'}</div>
+                ${issues ? `<ul>${report.issues.map(issue => `<li>${escapeHtml(issue)}</li>`).join('')}</ul>` : ''}
+                <pre>${escapeHtml(report.css)}</pre>
+                ${fontSourcePath(font) ? `
+                <div class="fallback-generator">
+                    <select class="fallback-select" data-font-id="${escapeHtml(font.id)}">
+                        <option value="">Choose a local fallback font...</option>
+                    </select>
+                    <button type="button" class="fallback-button" data-font-id="${escapeHtml(font.id)}">Generate Fallback CSS</button>
+                    <pre class="fallback-css" style="display: none;"></pre>
+                </div>` : ''}
+            </details>`;
+    }
+
+    // Fill a fallback select with the other parseable fonts in the results
+    populateFallbackSelect(select) {
+        if (select.options.length > 1) return;
+        this.fonts
+            .filter(font => font.id !== select.dataset.fontId && fontSourcePath(font))
+            .sort((a, b) => a.name.localeCompare(b.name))
+            .forEach(font => {
+                const option = document.createElement('option');
+                option.value = fontSourcePath(font);
+                option.textContent = font.name;
+                select.appendChild(option);
+            });
+    }
+
+    async generateFallback(button) {
+        const generator = button.closest('.fallback-generator');
+        const select = generator.querySelector('.fallback-select');
+        const output = generator.querySelector('.fallback-css');
+        const font = this.fontById(button.dataset.fontId);
+        if (!font || !select.value) {
+            alert('Choose a fallback font first.');
+            return;
+        }
+
+        const params = new URLSearchParams({ font: fontSourcePath(font), fallback: select.value });
+        const response = await fetch(`/api/fallback?${params}`);
+        const data = await response.json();
+        output.style.display = 'block';
+        output.textContent = response.ok ? data.css : data.error;
+    }
+
+    // Refresh a card after its library entry changed
+    refreshLibraryControls(fontId) {
+        this.fonts.forEach((font, index) => {
+            if (font.id !== fontId) return;
+            this.loadedFonts.delete(font.name);
+            this.visibleItems.delete(index);
+            this.loadFontItem(index);
+        });
+        this.applyFilters();
+    }
+
+    fontById(fontId) {
+        return this.fonts.find(font => font.id === fontId);
+    }
+
+    generateFormatButtons(font) {
+        const status = font.status || {};
+        const buttons = Object.entries(font.formats)
             .map(([format, url]) => `
-                <a href="${url}" download class="format-button">
+                <a href="${escapeHtml(url)}" download class="format-button"
+                    title="${formatStatusTitle(status[format])}">
                     ${format.toUpperCase().replace('.', '')}
                 </a>
-            `).join('');
+            `);
+        const failures = Object.entries(status)
+            .filter(([, formatStatus]) => formatStatus.state === 'failed')
+            .map(([format, formatStatus]) => `
+                <span class="format-button failed" title="${escapeHtml(formatStatus.reason || 'Conversion failed')}">
+                    ${format.toUpperCase().replace('.', '')} failed
+                </span>
+            `);
+        return buttons.concat(failures).join('');
     }
 
     sort(order) {
@@ -119,16 +297,28 @@ class VirtualFontList {
         this.visibleItems.clear();
         this.renderItems();
         this.loadAllFonts();
-        this.updateDownloadAllButton();
+        this.applyFilters();
     }
 
     filter(searchTerm) {
         this.currentFilter = searchTerm.toLowerCase();
+        this.applyFilters();
+    }
+
+    setView(view) {
+        this.currentView = view;
+        this.applyFilters();
+    }
+
+    applyFilters() {
+        const searchTerm = this.currentFilter || '';
+        const view = this.currentView || '';
         let visibleCount = 0;
         
         Array.from(this.container.children).forEach(element => {
+            const font = this.fonts[parseInt(element.dataset.index)];
             const fontName = element.dataset.fontName;
-            const matches = fontName.includes(this.currentFilter);
+            const matches = fontName.includes(searchTerm) && fontLibrary.matches(font, view);
             element.style.display = matches ? '' : 'none';
             if (matches) visibleCount++;
         });
@@ -146,6 +336,12 @@ class VirtualFontList {
         this.updateDownloadAllButton();
     }
 
+    getVisibleFonts() {
+        return Array.from(this.container.children)
+            .filter(el => el.style.display !== 'none')
+            .map(el => this.fonts[parseInt(el.dataset.index)]);
+    }
+
     updateDownloadAllButton() {
         const downloadContainer = document.getElementById('downloadAllFonts');
         const downloadBtn = downloadContainer.querySelector('button');
@@ -178,30 +374,16 @@ class VirtualFontList {
         }
     
         // Get currently visible fonts to download
-        const visibleFontIndices = Array.from(this.container.children)
-            .filter(el => el.style.display !== 'none')
-            .map(el => parseInt(el.dataset.index));
-    
-        // Filter fonts based on visible items
-        const fontsToDownload = visibleFontIndices.map(index => this.fonts[index]);
+        const fontsToDownload = this.getVisibleFonts();
     
         // Show loading state
         const originalText = downloadBtn.textContent;
         downloadBtn.textContent = 'Preparing Download...';
     
-        // Prepare the font data with standardized paths
+        // The server resolves the files of each font from its record of the scan
         const fontData = {
-            fonts: fontsToDownload.map(font => ({
-                name: font.name,
-                formats: Object.fromEntries(
-                    Object.entries(font.formats).map(([ext, originalPath]) => [
-                        ext, 
-                        originalPath.startsWith('/download?path=') 
-                            ? originalPath 
-                            : `/download?path=${encodeURIComponent(originalPath)}`
-                    ])
-                )
-            }))
+            job: this.options.jobId,
+            fonts: fontsToDownload.map(font => font.id)
         };
     
         // Send request to create zip file
@@ -217,12 +399,19 @@ class VirtualFontList {
                 // Try to get error message from response
                 return response.text().then(text => {
                     console.error('Download error response:', text);
-                    throw new Error(text || 'Download failed');
+                    let message = text;
+                    try {
+                        message = JSON.parse(text).error || text;
+                    } catch (e) {
+                        // Plain text error
+                    }
+                    throw new Error(message || 'Download failed');
                 });
             }
-            return response.blob();
+            const skipped = parseInt(response.headers.get('X-Fonts-Skipped') || '0');
+            return response.blob().then(blob => ({ blob, skipped }));
         })
-        .then(blob => {
+        .then(({ blob, skipped }) => {
             // Create download link
             const url = window.URL.createObjectURL(blob);
             const a = document.createElement('a');
@@ -234,7 +423,9 @@ class VirtualFontList {
             document.body.removeChild(a);
     
             // Show download complete message and keep button disabled
-            downloadBtn.textContent = 'Zipped Fonts Downloaded';
+            downloadBtn.textContent = skipped > 0
+                ? `Zipped Fonts Downloaded (${skipped} skipped, see manifest.json)`
+                : 'Zipped Fonts Downloaded';
             downloadBtn.disabled = true;
             downloadBtn.classList.add('disabled');
         })
@@ -259,12 +450,476 @@ class VirtualFontList {
     }
 
     destroy() {
+        if (this.filterFrame) {
+            cancelAnimationFrame(this.filterFrame);
+            this.filterFrame = null;
+        }
         this.container.innerHTML = '';
         this.visibleItems.clear();
         this.loadedFonts.clear();
     }
 }
 
+// FontLibrary keeps favorites, tags and collections in sync with the server
+class FontLibrary {
+    constructor() {
+        this.data = { fonts: {}, collections: {} };
+    }
+
+    async load() {
+        const response = await fetch('/api/library');
+        this.update(await response.json());
+    }
+
+    update(data) {
+        this.data = {
+            fonts: data.fonts || {},
+            collections: data.collections || {}
+        };
+        this.updateViewOptions();
+    }
+
+    isFavorite(fontId) {
+        const entry = this.data.fonts[fontId];
+        return !!(entry && entry.favorite);
+    }
+
+    tags(fontId) {
+        const entry = this.data.fonts[fontId];
+        return (entry && entry.tags) || [];
+    }
+
+    allTags() {
+        const tags = new Set();
+        Object.values(this.data.fonts).forEach(entry => (entry.tags || []).forEach(tag => tags.add(tag)));
+        return Array.from(tags).sort();
+    }
+
+    matches(font, view) {
+        if (!view) return true;
+        if (view === 'favorites') return this.isFavorite(font.id);
+        if (view.startsWith('tag:')) return this.tags(font.id).includes(view.slice(4));
+        if (view.startsWith('collection:')) {
+            const collection = this.data.collections[view.slice(11)];
+            return !!(collection && collection.fonts.includes(font.id));
+        }
+        return true;
+    }
+
+    async post(url, body) {
+        const response = await fetch(url, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(body)
+        });
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || 'Library update failed');
+        }
+        this.update(data);
+    }
+
+    updateFont(font, changes) {
+        return this.post('/api/library/fonts', { id: font.id, name: font.name, ...changes });
+    }
+
+    saveCollection(name, fonts) {
+        return this.post('/api/library/collections', {
+            name: name,
+            fonts: fonts.map(font => ({ id: font.id, name: font.name }))
+        });
+    }
+
+    async deleteCollection(name) {
+        const response = await fetch(`/api/library/collections?name=${encodeURIComponent(name)}`, { method: 'DELETE' });
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || 'Failed to delete collection');
+        }
+        this.update(data);
+    }
+
+    async importFile(file) {
+        const text = await file.text();
+        const response = await fetch('/api/library/import', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: text
+        });
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || 'Import failed');
+        }
+        this.update(data);
+    }
+
+    updateViewOptions() {
+        const select = document.getElementById('libraryView');
+        const current = select.value;
+        const options = [
+            ['', 'All Fonts'],
+            ['favorites', 'Favorites'],
+            ...Object.keys(this.data.collections).sort().map(name => [`collection:${name}`, `Collection: ${name}`]),
+            ...this.allTags().map(tag => [`tag:${tag}`, `Tag: ${tag}`])
+        ];
+        select.innerHTML = options
+            .map(([value, label]) => `<option value="${escapeHtml(value)}">${escapeHtml(label)}</option>`)
+            .join('');
+        select.value = options.some(([value]) => value === current) ? current : '';
+        document.getElementById('deleteCollection').style.display =
+            select.value.startsWith('collection:') ? '' : 'none';
+    }
+}
+
+// fontSourcePath returns the file path of a font's original TTF, OTF or WOFF file
+function fontSourcePath(font) {
+    for (const ext of ['.ttf', '.otf', '.woff']) {
+        const url = font.formats[ext];
+        if (!url) continue;
+        const path = new URL(url, window.location.origin).searchParams.get('path');
+        if (path && path.toLowerCase().endsWith(ext)) return path;
+    }
+    return '';
+}
+
+// Describe how a format was obtained for the format button tooltip
+function formatStatusTitle(status) {
+    const state = (status && status.state) || 'original';
+    if (state === 'on-demand') {
+        return 'Converted on first download';
+    }
+    return state;
+}
+
+function escapeHtml(text) {
+    return String(text)
+        .replace(/&/g, '&amp;')
+        .replace(/</g, '&lt;')
+        .replace(/>/g, '&gt;')
+        .replace(/"/g, '&quot;')
+        .replace(/'/g, '&#39;');
+}
+
+// Quote text as a CSS string. CSS.escape also escapes '<' and '/', so the
+// result cannot close a <style> element; escape it again for HTML attributes.
+function cssString(text) {
+    return `"${CSS.escape(String(text))}"`;
+}
+
+const fontLibrary = new FontLibrary();
+
+const MAX_COMPARE_FONTS = 6;
+const METRIC_LINES = [
+    { key: 'ascender', label: 'Ascender', color: '#7b61ff' },
+    { key: 'capHeight', label: 'Cap height', color: '#e2574c' },
+    { key: 'xHeight', label: 'x-height', color: '#2e9d5b' },
+    { key: 'baseline', label: 'Baseline', color: '#4a90e2' },
+    { key: 'descender', label: 'Descender', color: '#f5a623' }
+];
+
+// CompareView renders 2-6 selected fonts together with aligned baselines and metric overlays,
+// or as heading/body pairings. The selection is encoded in the URL hash so it can be shared.
+class CompareView {
+    constructor() {
+        this.selected = [];
+        this.mode = 'compare';
+        this.heading = '';
+        this.body = '';
+        this.loadedFaces = new Set();
+        this.pendingState = null;
+    }
+
+    isSelected(fontId) {
+        return this.selected.includes(fontId);
+    }
+
+    toggle(fontId, checked) {
+        if (checked && !this.isSelected(fontId)) {
+            if (this.selected.length >= MAX_COMPARE_FONTS) {
+                alert(`You can compare up to ${MAX_COMPARE_FONTS} fonts.`);
+                return false;
+            }
+            this.selected.push(fontId);
+        } else if (!checked) {
+            this.selected = this.selected.filter(id => id !== fontId);
+        }
+        this.updateBar();
+        if (this.isOpen()) {
+            this.render();
+        }
+        return true;
+    }
+
+    clear() {
+        this.selected = [];
+        document.querySelectorAll('.compare-checkbox').forEach(box => { box.checked = false; });
+        this.close();
+        this.updateBar();
+    }
+
+    fonts() {
+        if (!virtualFontList) return [];
+        return this.selected.map(id => virtualFontList.fontById(id)).filter(Boolean);
+    }
+
+    updateBar() {
+        const bar = document.getElementById('compareBar');
+        const count = this.selected.length;
+        bar.style.display = count > 0 ? 'flex' : 'none';
+        document.getElementById('compareCount').textContent =
+            count === 1 ? '1 font selected' : `${count} fonts selected`;
+        document.getElementById('openCompare').disabled = count < 2;
+    }
+
+    isOpen() {
+        return document.getElementById('compareView').style.display !== 'none';
+    }
+
+    open() {
+        if (this.selected.length < 2) {
+            alert('Select at least 2 fonts to compare.');
+            return;
+        }
+        document.getElementById('compareView').style.display = 'block';
+        this.render();
+        document.getElementById('compareView').scrollIntoView({ behavior: 'smooth' });
+    }
+
+    close() {
+        document.getElementById('compareView').style.display = 'none';
+        this.updateHash();
+    }
+
+    setMode(mode) {
+        this.mode = mode;
+        document.querySelectorAll('.compare-mode').forEach(button => {
+            button.classList.toggle('active', button.dataset.mode === mode);
+        });
+        this.render();
+    }
+
+    async loadFace(font) {
+        const family = `compare-${font.id}`;
+        if (!this.loadedFaces.has(family)) {
+            const face = new FontFace(family, `url(${cssString(font.preview)})`);
+            await face.load();
+            document.fonts.add(face);
+            this.loadedFaces.add(family);
+        }
+        return family;
+    }
+
+    async render() {
+        const fonts = this.fonts();
+        const content = document.getElementById('compareContent');
+        document.getElementById('pairingControls').style.display = this.mode === 'pair' ? 'flex' : 'none';
+        this.updateHash();
+
+        if (fonts.length < 2) {
+            content.innerHTML = '<div class="error-message">Select at least 2 fonts to compare.</div>';
+            return;
+        }
+
+        if (this.mode === 'pair') {
+            await this.renderPairing(fonts, content);
+        } else {
+            await this.renderComparison(fonts, content);
+        }
+    }
+
+    async renderComparison(fonts, content) {
+        const size = parseInt(document.getElementById('fontSize').value) * 2;
+        const text = document.getElementById('sampleText').value;
+        const padding = 12;
+
+        // Share a single baseline position across rows so baselines line up
+        const extents = fonts.map(font => {
+            const m = font.metrics;
+            if (!m) return { above: size, below: size * 0.3 };
+            return {
+                above: Math.max(m.ascender, m.capHeight || 0) / m.unitsPerEm * size,
+                below: Math.abs(m.descender) / m.unitsPerEm * size
+            };
+        });
+        const baseline = padding + Math.max(...extents.map(e => e.above));
+        const height = baseline + Math.max(...extents.map(e => e.below)) + padding;
+
+        content.innerHTML = fonts.map(font => `
+            <div class="compare-row">
+                <h3>${escapeHtml(font.name)}${font.metrics ? '' : ' (metrics unavailable)'}</h3>
+                <canvas data-font-id="${escapeHtml(font.id)}"></canvas>
+            </div>`).join('');
+
+        for (const font of fonts) {
+            const canvas = content.querySelector(`canvas[data-font-id=${cssString(font.id)}]`);
+            try {
+                const family = await this.loadFace(font);
+                this.drawFont(canvas, font, family, text, size, baseline, height);
+            } catch (error) {
+                console.error(`Failed to load ${font.name}:`, error);
+                canvas.replaceWith(Object.assign(document.createElement('div'), {
+                    className: 'error-message',
+                    textContent: `Failed to load ${font.name}`
+                }));
+            }
+        }
+    }
+
+    drawFont(canvas, font, family, text, size, baseline, height) {
+        const ratio = window.devicePixelRatio || 1;
+        const width = canvas.parentElement.clientWidth - 32;
+        canvas.width = width * ratio;
+        canvas.height = height * ratio;
+        canvas.style.height = `${height}px`;
+
+        const ctx = canvas.getContext('2d');
+        ctx.scale(ratio, ratio);
+        const styles = getComputedStyle(document.body);
+
+        const m = font.metrics;
+        const positions = { baseline: baseline };
+        if (m) {
+            const scale = size / m.unitsPerEm;
+            positions.ascender = baseline - m.ascender * scale;
+            positions.descender = baseline - m.descender * scale;
+            if (m.capHeight) positions.capHeight = baseline - m.capHeight * scale;
+            if (m.xHeight) positions.xHeight = baseline - m.xHeight * scale;
+        }
+
+        ctx.font = '11px sans-serif';
+        METRIC_LINES.forEach(line => {
+            const y = positions[line.key];
+            if (y === undefined) return;
+            ctx.strokeStyle = line.color;
+            ctx.fillStyle = line.color;
+            ctx.setLineDash(line.key === 'baseline' ? [] : [4, 4]);
+            ctx.beginPath();
+            ctx.moveTo(0, Math.round(y) + 0.5);
+            ctx.lineTo(width, Math.round(y) + 0.5);
+            ctx.stroke();
+            ctx.textAlign = 'right';
+            ctx.fillText(line.label, width - 4, y - 3);
+        });
+
+        ctx.setLineDash([]);
+        ctx.textAlign = 'left';
+        ctx.textBaseline = 'alphabetic';
+        ctx.fillStyle = styles.getPropertyValue('--text-color').trim() || '#333';
+        ctx.font = `${size}px ${cssString(family)}`;
+        ctx.fillText(text, 8, baseline);
+    }
+
+    async renderPairing(fonts, content) {
+        const ids = fonts.map(font => font.id);
+        if (!ids.includes(this.heading)) this.heading = ids[0];
+        if (!ids.includes(this.body) || (this.body === this.heading && ids.length > 1)) {
+            this.body = ids.find(id => id !== this.heading);
+        }
+
+        const options = fonts
+            .map(font => `<option value="${escapeHtml(font.id)}">${escapeHtml(font.name)}</option>`)
+            .join('');
+        const headingSelect = document.getElementById('headingFont');
+        const bodySelect = document.getElementById('bodyFont');
+        headingSelect.innerHTML = options;
+        bodySelect.innerHTML = options;
+        headingSelect.value = this.heading;
+        bodySelect.value = this.body;
+        this.updateHash();
+
+        const headingFont = virtualFontList.fontById(this.heading);
+        const bodyFont = virtualFontList.fontById(this.body);
+        try {
+            const headingFamily = await this.loadFace(headingFont);
+            const bodyFamily = await this.loadFace(bodyFont);
+            const text = escapeHtml(document.getElementById('sampleText').value);
+            content.innerHTML = `
+                <div class="pairing-sample">
+                    <h2 style="font-family: ${escapeHtml(cssString(headingFamily))};">${text}</h2>
+                    <p style="font-family: ${escapeHtml(cssString(bodyFamily))};">${text}. Body copy is set in
+                        ${escapeHtml(bodyFont.name)} while the heading above uses ${escapeHtml(headingFont.name)}.
+                        A good pairing balances contrast with harmony: the heading should stand out without
+                        clashing with the texture of the paragraph beneath it.</p>
+                    <p style="font-family: ${escapeHtml(cssString(bodyFamily))};">0123456789 &mdash; ABCDEFGHIJKLMNOPQRSTUVWXYZ
+                        abcdefghijklmnopqrstuvwxyz</p>
+                </div>`;
+        } catch (error) {
+            content.innerHTML = `<div class="error-message">Failed to load fonts: ${escapeHtml(error.message)}</div>`;
+        }
+    }
+
+    setPairing(heading, body) {
+        this.heading = heading;
+        this.body = body;
+        this.render();
+    }
+
+    // Encode the current selection in the URL hash
+    updateHash() {
+        const params = new URLSearchParams();
+        const fontDir = document.getElementById('fontDir').value;
+        if (this.selected.length > 0 && fontDir) {
+            params.set('dir', fontDir);
+            params.set('compare', this.selected.join(','));
+            params.set('mode', this.mode);
+            params.set('text', document.getElementById('sampleText').value);
+            params.set('size', document.getElementById('fontSize').value);
+            if (this.mode === 'pair') {
+                params.set('heading', this.heading);
+                params.set('body', this.body);
+            }
+            if (!this.isOpen()) {
+                params.set('view', 'grid');
+            }
+        }
+        const hash = params.toString();
+        history.replaceState(null, '', hash ? `#${hash}` : window.location.pathname + window.location.search);
+    }
+
+    // Read a shared selection from the URL hash. Returns the directory to scan, if any.
+    readHash() {
+        const params = new URLSearchParams(window.location.hash.slice(1));
+        if (!params.get('dir') || !params.get('compare')) return '';
+
+        this.pendingState = {
+            selected: params.get('compare').split(',').filter(Boolean).slice(0, MAX_COMPARE_FONTS),
+            mode: params.get('mode') === 'pair' ? 'pair' : 'compare',
+            heading: params.get('heading') || '',
+            body: params.get('body') || '',
+            open: params.get('view') !== 'grid'
+        };
+        if (params.get('text')) document.getElementById('sampleText').value = params.get('text');
+        if (params.get('size')) document.getElementById('fontSize').value = params.get('size');
+        return params.get('dir');
+    }
+
+    // Apply a selection read from the URL hash once scan results are available
+    restorePending() {
+        const state = this.pendingState;
+        this.pendingState = null;
+        if (!state || !virtualFontList) return;
+
+        this.selected = state.selected.filter(id => virtualFontList.fontById(id));
+        this.heading = state.heading;
+        this.body = state.body;
+        this.selected.forEach(id => virtualFontList.refreshLibraryControls(id));
+        this.updateBar();
+        this.setModeButtons(state.mode);
+        if (state.open && this.selected.length >= 2) {
+            this.open();
+        }
+    }
+
+    setModeButtons(mode) {
+        this.mode = mode;
+        document.querySelectorAll('.compare-mode').forEach(button => {
+            button.classList.toggle('active', button.dataset.mode === mode);
+        });
+    }
+}
+
+const compareView = new CompareView();
+
 // Theme toggle
 function toggleTheme() {
     document.body.classList.toggle('dark-theme');
@@ -277,39 +932,146 @@ if (localStorage.getItem('theme') === 'dark') {
     document.body.classList.add('dark-theme');
 }
 
-// Progress handling
-function initializeProgress() {
+// Progress handling: typed events for one scan job. EventSource reconnects on
+// its own and the server resumes after the last event it delivered.
+function initializeProgress(jobId) {
     const loading = document.getElementById('loading');
     const loadingDetails = document.getElementById('loadingDetails');
     const progressBar = document.getElementById('progressBar');
-    
+    let failures = 0;
+
     loading.style.display = 'block';
     progressBar.style.width = '0%';
+    loadingDetails.textContent = 'Starting scan...';
 
-    const eventSource = new EventSource('/progress');
-    
-    eventSource.onmessage = function(event) {
-        loadingDetails.textContent = event.data;
-        
-        const match = event.data.match(/(\d+)\/(\d+)/);
-        if (match) {
-            const [current, total] = match.slice(1).map(Number);
-            const percentage = (current / total) * 100;
-            progressBar.style.width = `${percentage}%`;
-        }
+    const setProgress = (current, total) => {
+        progressBar.style.width = total > 0 ? `${(current / total) * 100}%` : '0%';
     };
+    const failureText = () => failures > 0 ? ` · ${failures} failed` : '';
 
-    eventSource.onerror = function() {
-        eventSource.close();
-        loading.style.display = 'none';
+    const eventSource = new EventSource(`/progress?job=${encodeURIComponent(jobId)}`);
+    const on = (type, handler) => {
+        eventSource.addEventListener(type, event => handler(JSON.parse(event.data)));
     };
 
+    on('scan_started', data => {
+        loadingDetails.textContent = `Scanning ${data.fontDir}...`;
+    });
+
+    on('font_found', data => {
+        setProgress(data.found, data.total);
+        loadingDetails.textContent = `Reading fonts: ${data.found}/${data.total} - ${data.name}`;
+    });
+
+    on('conversion_progress', data => {
+        setProgress(data.current, data.total);
+        let text = `${data.format.replace('.', '').toUpperCase()} conversion: ${data.current}/${data.total} - ${data.currentFont}`;
+        if (data.current > 0 && data.current < data.total) {
+            const remaining = (data.elapsedMs / data.current) * (data.total - data.current);
+            text += ` · about ${formatDuration(remaining)} left`;
+        }
+        loadingDetails.textContent = text + failureText();
+    });
+
+    on('conversion_failed', data => {
+        failures++;
+        console.warn(`Conversion of ${data.font} to ${data.format} failed: ${data.error}`);
+    });
+
+    on('scan_complete', data => {
+        setProgress(1, 1);
+        loadingDetails.textContent = data.error ? data.error : `Scan complete${failureText()}`;
+    });
+
     return eventSource;
 }
 
+// Format a duration in milliseconds as e.g. "45s" or "3m 20s"
+function formatDuration(ms) {
+    const seconds = Math.max(1, Math.round(ms / 1000));
+    if (seconds < 60) {
+        return `${seconds}s`;
+    }
+    return `${Math.floor(seconds / 60)}m ${seconds % 60}s`;
+}
+
+// Show format counts and timings for the last scan
+function showScanSummary(summary) {
+    const element = document.getElementById('scanSummary');
+    if (!summary) {
+        element.textContent = '';
+        return;
+    }
+    const parts = [
+        `${summary.original} original`,
+        `${summary.onDemand} on demand`,
+        `${summary.converted} converted`,
+        `${summary.cached} cached`
+    ];
+    if (summary.failed > 0) {
+        parts.push(`${summary.failed} failed`);
+    }
+    if (summary.truncated) {
+        parts.push('stopped at the maximum number of files');
+    }
+    const seconds = (summary.timings.totalMs / 1000).toFixed(1);
+    element.textContent = `${parts.join(' · ')} · ${seconds}s`;
+    element.classList.toggle('has-failures', summary.failed > 0);
+}
+
+// Read a /api/scan NDJSON response and pass each event to onEvent
+async function streamScan(params, signal, onEvent) {
+    const response = await fetch(`/api/scan?${params}`, { signal });
+    if (!response.ok) {
+        const data = await response.json().catch(() => ({}));
+        throw new Error(data.error || `Scan failed with status ${response.status}`);
+    }
+
+    const reader = response.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = '';
+    for (;;) {
+        const { value, done } = await reader.read();
+        if (done) break;
+        buffer += decoder.decode(value, { stream: true });
+
+        let newline;
+        while ((newline = buffer.indexOf('\n')) >= 0) {
+            const line = buffer.slice(0, newline).trim();
+            buffer = buffer.slice(newline + 1);
+            if (line) {
+                onEvent(JSON.parse(line));
+            }
+        }
+    }
+}
+
 // Global instance
 let virtualFontList;
 
+// The scan currently in progress, if any
+let currentScan = null;
+
+function newJobId() {
+    if (window.crypto && crypto.randomUUID) {
+        return crypto.randomUUID();
+    }
+    return Date.now().toString(36) + Math.random().toString(36).slice(2);
+}
+
+// Cancel the running scan on the server and abort the request
+async function cancelScan() {
+    if (!currentScan) return;
+    const scan = currentScan;
+    currentScan = null;
+    try {
+        await fetch(`/api/jobs/${encodeURIComponent(scan.jobId)}`, { method: 'DELETE' });
+    } catch (error) {
+        console.error('Failed to cancel scan:', error);
+    }
+    scan.controller.abort();
+}
+
 // Document ready handler
 document.addEventListener('DOMContentLoaded', function() {
     // Form submit handler
@@ -321,6 +1083,9 @@ document.addEventListener('DOMContentLoaded', function() {
         }
 
         document.getElementById('filterInput').value = '';
+        if (!compareView.pendingState) {
+            compareView.clear();
+        }
         const results = document.getElementById('results');
         const message = document.getElementById('message');
         results.innerHTML = '';
@@ -328,46 +1093,86 @@ document.addEventListener('DOMContentLoaded', function() {
         document.getElementById('totalFonts').style.display = 'none';
 
         try {
-            const fontDir = document.getElementById('fontDir').value;
-            const eventSource = initializeProgress();
-            const response = await fetch(`/generate?fontDir=${encodeURIComponent(fontDir)}`);
-            const data = await response.json();
-            
-            eventSource.close();
-            document.getElementById('loading').style.display = 'none';
-
-            if (data.error) {
-                message.innerHTML = `<div class="error-message">${data.error}</div>`;
-                return;
+            // A shared server offers named libraries instead of a directory path
+            const fontDir = document.getElementById('fontDir');
+            const params = new URLSearchParams({ [fontDir.name]: fontDir.value });
+            const featureFilter = document.getElementById('featureFilter').value.trim();
+            if (featureFilter) {
+                params.set('feature', featureFilter);
             }
+            const scan = { jobId: newJobId(), controller: new AbortController() };
+            params.set('job', scan.jobId);
+            currentScan = scan;
 
             virtualFontList = new VirtualFontList(results, {
                 itemHeight: 300,
-                defaultFontSize: parseInt(document.getElementById('fontSize').value)
+                defaultFontSize: parseInt(document.getElementById('fontSize').value),
+                jobId: scan.jobId
             });
-            
-            virtualFontList.init(data);
+            virtualFontList.init([]);
+            virtualFontList.setView(document.getElementById('libraryView').value);
 
-            const fontCountMessage = document.getElementById('fontCountMessage');
-            if (data.length === 0) {
-                fontCountMessage.textContent = 'No fonts found';
-            } else if (data.length === 1) {
-                fontCountMessage.textContent = '1 font found';
-            } else {
-                fontCountMessage.textContent = `${data.length} fonts found`;
+            // Fonts are shown as soon as they are found, so move the progress box out of the way
+            const loading = document.getElementById('loading');
+            const eventSource = initializeProgress(scan.jobId);
+            let summary = null;
+            let scanError = null;
+            try {
+                await streamScan(params, scan.controller.signal, event => {
+                    switch (event.type) {
+                        case 'font':
+                            virtualFontList.addFont(event.font);
+                            loading.classList.add('docked');
+                            document.getElementById('totalFonts').style.display = 'block';
+                            break;
+                        case 'update':
+                            virtualFontList.updateFont(event.font);
+                            break;
+                        case 'summary':
+                            summary = event.summary;
+                            break;
+                        case 'error':
+                            scanError = event.error;
+                            break;
+                    }
+                });
+            } finally {
+                eventSource.close();
+                if (currentScan === scan) currentScan = null;
+                loading.style.display = 'none';
+                loading.classList.remove('docked');
             }
+
+            if (scanError) {
+                message.innerHTML = `<div class="error-message">${escapeHtml(scanError)}</div>`;
+                return;
+            }
+
+            virtualFontList.sort(document.getElementById('sortOrder').value);
+            compareView.restorePending();
+            showScanSummary(summary);
             document.getElementById('totalFonts').style.display = 'block';
             
         } catch (error) {
             document.getElementById('loading').style.display = 'none';
-            message.innerHTML = `<div class="error-message">Error processing request: ${error.message}</div>`;
+            document.getElementById('loading').classList.remove('docked');
+            if (error.name === 'AbortError') {
+                message.innerHTML = '<div class="error-message">Scan cancelled</div>';
+                return;
+            }
+            message.innerHTML = `<div class="error-message">Error processing request: ${escapeHtml(error.message)}</div>`;
         }
     });
 
+    document.getElementById('cancelScan').addEventListener('click', cancelScan);
+
     document.getElementById('fontSize').addEventListener('change', function(e) {
         if (virtualFontList) {
             virtualFontList.updateFontSize(parseInt(e.target.value));
         }
+        if (compareView.isOpen()) {
+            compareView.render();
+        }
     });
 
     // Column selector
@@ -400,9 +1205,11 @@ document.addEventListener('DOMContentLoaded', function() {
             virtualFontList.destroy();
             virtualFontList = new VirtualFontList(document.getElementById('results'), {
                 itemHeight: 300,
-                defaultFontSize: parseInt(document.getElementById('fontSize').value)
+                defaultFontSize: parseInt(document.getElementById('fontSize').value),
+                jobId: scan.jobId
             });
             virtualFontList.init(fonts);
+            virtualFontList.setView(document.getElementById('libraryView').value);
             
             // Reapply filter if it exists
             if (currentFilter) {
@@ -410,5 +1217,171 @@ document.addEventListener('DOMContentLoaded', function() {
                 virtualFontList.filter(currentFilter);
             }
         }
+        if (compareView.isOpen()) {
+            compareView.render();
+        }
+    });
+
+    // OpenType feature toggles
+    document.getElementById('results').addEventListener('click', function(e) {
+        const button = e.target.closest('.feature-toggle');
+        if (button && virtualFontList) {
+            virtualFontList.toggleFeature(button);
+        }
+    });
+
+    // Fallback CSS generator in the metrics details
+    document.getElementById('results').addEventListener('focusin', function(e) {
+        if (e.target.classList.contains('fallback-select') && virtualFontList) {
+            virtualFontList.populateFallbackSelect(e.target);
+        }
+    });
+
+    document.getElementById('results').addEventListener('click', function(e) {
+        if (e.target.classList.contains('fallback-button') && virtualFontList) {
+            virtualFontList.generateFallback(e.target).catch(error => {
+                alert(`Failed to generate fallback: ${error.message}`);
+            });
+        }
+    });
+
+    // Compare and pairing view
+    document.getElementById('results').addEventListener('change', function(e) {
+        if (!e.target.classList.contains('compare-checkbox')) return;
+        if (!compareView.toggle(e.target.dataset.fontId, e.target.checked)) {
+            e.target.checked = false;
+        }
+    });
+
+    document.getElementById('openCompare').addEventListener('click', () => compareView.open());
+    document.getElementById('clearCompare').addEventListener('click', () => compareView.clear());
+    document.getElementById('closeCompare').addEventListener('click', () => compareView.close());
+
+    document.querySelectorAll('.compare-mode').forEach(button => {
+        button.addEventListener('click', () => compareView.setMode(button.dataset.mode));
+    });
+
+    document.getElementById('headingFont').addEventListener('change', function(e) {
+        compareView.setPairing(e.target.value, compareView.body);
+    });
+
+    document.getElementById('bodyFont').addEventListener('change', function(e) {
+        compareView.setPairing(compareView.heading, e.target.value);
+    });
+
+    document.getElementById('swapPairing').addEventListener('click', function() {
+        compareView.setPairing(compareView.body, compareView.heading);
+    });
+
+    document.getElementById('copyCompareLink').addEventListener('click', async function() {
+        compareView.updateHash();
+        try {
+            await navigator.clipboard.writeText(window.location.href);
+            this.textContent = 'Link Copied';
+            setTimeout(() => { this.textContent = 'Copy Link'; }, 2000);
+        } catch (error) {
+            prompt('Copy this link:', window.location.href);
+        }
+    });
+
+    // Restore a shared comparison from the URL
+    const sharedDir = compareView.readHash();
+    if (sharedDir) {
+        document.getElementById('fontDir').value = sharedDir;
+        document.getElementById('previewForm').requestSubmit();
+    }
+
+    // Library: load favorites, tags and collections
+    fontLibrary.load().catch(error => console.error('Failed to load library:', error));
+
+    document.getElementById('libraryView').addEventListener('change', function(e) {
+        document.getElementById('deleteCollection').style.display =
+            e.target.value.startsWith('collection:') ? '' : 'none';
+        if (virtualFontList) {
+            virtualFontList.setView(e.target.value);
+            virtualFontList.resetDownloadButton();
+        }
+    });
+
+    // Favorite toggles and tag edits on font cards
+    document.getElementById('results').addEventListener('click', async function(e) {
+        const button = e.target.closest('.favorite-button');
+        if (!button || !virtualFontList) return;
+        const font = virtualFontList.fontById(button.dataset.fontId);
+        if (!font) return;
+        try {
+            await fontLibrary.updateFont(font, { favorite: !fontLibrary.isFavorite(font.id) });
+            virtualFontList.refreshLibraryControls(font.id);
+        } catch (error) {
+            alert(`Failed to update favorite: ${error.message}`);
+        }
+    });
+
+    document.getElementById('results').addEventListener('change', async function(e) {
+        if (!e.target.classList.contains('tags-input') || !virtualFontList) return;
+        const font = virtualFontList.fontById(e.target.dataset.fontId);
+        if (!font) return;
+        try {
+            await fontLibrary.updateFont(font, { tags: e.target.value.split(',') });
+            virtualFontList.refreshLibraryControls(font.id);
+        } catch (error) {
+            alert(`Failed to update tags: ${error.message}`);
+        }
+    });
+
+    document.getElementById('saveCollection').addEventListener('click', async function() {
+        if (!virtualFontList) {
+            alert('Search for fonts before creating a collection.');
+            return;
+        }
+        const fonts = virtualFontList.getVisibleFonts();
+        if (fonts.length === 0) {
+            alert('No fonts are shown.');
+            return;
+        }
+        const name = prompt(`Save ${fonts.length} shown font(s) as collection:`);
+        if (!name) return;
+        try {
+            await fontLibrary.saveCollection(name, fonts);
+            document.getElementById('libraryView').value = `collection:${name.trim()}`;
+            document.getElementById('libraryView').dispatchEvent(new Event('change'));
+        } catch (error) {
+            alert(`Failed to save collection: ${error.message}`);
+        }
+    });
+
+    document.getElementById('deleteCollection').addEventListener('click', async function() {
+        const view = document.getElementById('libraryView').value;
+        if (!view.startsWith('collection:')) return;
+        const name = view.slice(11);
+        if (!confirm(`Delete collection "${name}"?`)) return;
+        try {
+            await fontLibrary.deleteCollection(name);
+            document.getElementById('libraryView').dispatchEvent(new Event('change'));
+        } catch (error) {
+            alert(`Failed to delete collection: ${error.message}`);
+        }
+    });
+
+    document.getElementById('exportLibrary').addEventListener('click', function() {
+        window.location.href = '/api/library/export';
+    });
+
+    document.getElementById('importLibrary').addEventListener('click', function() {
+        document.getElementById('importLibraryFile').click();
+    });
+
+    document.getElementById('importLibraryFile').addEventListener('change', async function(e) {
+        const file = e.target.files[0];
+        e.target.value = '';
+        if (!file) return;
+        try {
+            await fontLibrary.importFile(file);
+            if (virtualFontList) {
+                virtualFontList.fonts.forEach(font => virtualFontList.refreshLibraryControls(font.id));
+            }
+        } catch (error) {
+            alert(`Failed to import library: ${error.message}`);
+        }
     });
 });
\ No newline at end of file
@@ -45,8 +45,28 @@ func initTemplates() error {
 	return nil
 }
 
+// IndexData configures the index page. In shared mode the directory input is
+// replaced by a choice of libraries and library editing is hidden.
+type IndexData struct {
+	Shared    bool
+	Libraries []string
+}
+
 // RenderIndex renders the index template
-func RenderIndex(w io.Writer) error {
+func RenderIndex(w io.Writer, data IndexData) error {
+	var err error
+	templatesOnce.Do(func() {
+		err = initTemplates()
+	})
+	if err != nil {
+		return err
+	}
+
+	return templates.ExecuteTemplate(w, "index.html", data)
+}
+
+// RenderDiagnostics renders the diagnostics template with a diagnostics report
+func RenderDiagnostics(w io.Writer, data any) error {
 	var err error
 	templatesOnce.Do(func() {
 		err = initTemplates()
@@ -55,7 +75,7 @@ func RenderIndex(w io.Writer) error {
 		return err
 	}
 
-	return templates.ExecuteTemplate(w, "index.html", nil)
+	return templates.ExecuteTemplate(w, "diagnostics.html", data)
 }
 
 // Add a function to serve the favicon